@@ -0,0 +1,62 @@
+package broker
+
+import (
+	"context"
+	"sync"
+)
+
+// Брокер сообщений для событий из outbox. Publish возвращает nil только
+// после того, как брокер принял сообщение.
+type Broker interface {
+	Publish(ctx context.Context, subject string, data []byte) error
+	// Обработчик вызывается для каждого сообщения темы; функция отменяет подписку
+	Subscribe(subject string, handler func(data []byte)) (func(), error)
+	Close() error
+}
+
+// Брокер внутри процесса: сообщения получают только подписчики этого же процесса
+type Memory struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[string]map[int]func(data []byte)
+}
+
+func NewMemory() *Memory {
+	return &Memory{subs: make(map[string]map[int]func(data []byte))}
+}
+
+func (m *Memory) Publish(ctx context.Context, subject string, data []byte) error {
+	m.mu.RLock()
+	handlers := make([]func(data []byte), 0, len(m.subs[subject]))
+	for _, handler := range m.subs[subject] {
+		handlers = append(handlers, handler)
+	}
+	m.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(data)
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(subject string, handler func(data []byte)) (func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	id := m.nextID
+	if m.subs[subject] == nil {
+		m.subs[subject] = make(map[int]func(data []byte))
+	}
+	m.subs[subject][id] = handler
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.subs[subject], id)
+	}, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,294 @@
+package broker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errClosed = errors.New("broker: connection closed")
+
+// Самая длинная пауза между попытками переподключения
+const natsMaxReconnectDelay = 5 * time.Second
+
+type natsSubscription struct {
+	subject string
+	handler func(data []byte)
+}
+
+// Адаптер NATS на базовом текстовом протоколе. Publish ждёт PONG на PING после PUB,
+// поэтому ошибка не возвращается, только если сервер принял сообщение.
+// -ERR не указывает, к какой команде относится, поэтому разрывает соединение
+// с ошибкой для всех ожидающих Publish. После обрыва соединение восстанавливается
+// в фоне вместе с подписками.
+type NATS struct {
+	addr string
+
+	mu      sync.Mutex
+	conn    net.Conn
+	w       *bufio.Writer
+	pongs   []chan error // Publish текущего соединения, ждущие PONG, в порядке отправки PING
+	subs    map[int]natsSubscription
+	nextSID int
+	closed  bool
+}
+
+// Подключение к серверу по адресу вида nats://host:port
+func NewNATS(url string) (*NATS, error) {
+	n := &NATS{addr: strings.TrimPrefix(url, "nats://"), subs: make(map[int]natsSubscription)}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := n.connect(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *NATS) Publish(ctx context.Context, subject string, data []byte) error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return errClosed
+	}
+	if n.conn == nil {
+		if err := n.connect(); err != nil {
+			n.mu.Unlock()
+			return err
+		}
+	}
+
+	fmt.Fprintf(n.w, "PUB %s %d\r\n", subject, len(data))
+	n.w.Write(data)
+	n.w.WriteString("\r\nPING\r\n")
+	if err := n.w.Flush(); err != nil {
+		n.dropLocked(n.conn, err)
+		n.mu.Unlock()
+		return err
+	}
+
+	pong := make(chan error, 1)
+	n.pongs = append(n.pongs, pong)
+	n.mu.Unlock()
+
+	select {
+	case err := <-pong:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (n *NATS) Subscribe(subject string, handler func(data []byte)) (func(), error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return nil, errClosed
+	}
+
+	n.nextSID++
+	sid := n.nextSID
+	n.subs[sid] = natsSubscription{subject: subject, handler: handler}
+
+	// Без соединения подписка будет отправлена при переподключении
+	if n.conn != nil {
+		fmt.Fprintf(n.w, "SUB %s %d\r\n", subject, sid)
+		if err := n.w.Flush(); err != nil {
+			n.dropLocked(n.conn, err)
+		}
+	}
+
+	return func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		delete(n.subs, sid)
+		if n.conn != nil {
+			fmt.Fprintf(n.w, "UNSUB %d\r\n", sid)
+			if err := n.w.Flush(); err != nil {
+				n.dropLocked(n.conn, err)
+			}
+		}
+	}, nil
+}
+
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.closed = true
+	if n.conn != nil {
+		n.dropLocked(n.conn, errClosed)
+	}
+	return nil
+}
+
+// Установка соединения и повторная отправка подписок; вызывается под n.mu
+func (n *NATS) connect() error {
+	conn, err := net.DialTimeout("tcp", n.addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := r.ReadString('\n')
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("broker: unexpected greeting %q", strings.TrimSpace(line))
+	}
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("CONNECT {\"verbose\":false,\"pedantic\":false,\"name\":\"em-test\"}\r\n")
+	for sid, sub := range n.subs {
+		fmt.Fprintf(w, "SUB %s %d\r\n", sub.subject, sid)
+	}
+	if err := w.Flush(); err != nil {
+		conn.Close()
+		return err
+	}
+
+	n.conn, n.w = conn, w
+	go n.readLoop(conn, r)
+	return nil
+}
+
+// Закрытие оборванного соединения: ожидающие Publish получают ошибку,
+// а подписки восстанавливаются в фоне. Вызывается под n.mu.
+func (n *NATS) dropLocked(conn net.Conn, err error) {
+	if n.conn != conn {
+		return
+	}
+
+	conn.Close()
+	n.conn, n.w = nil, nil
+	for _, pong := range n.pongs {
+		pong <- err
+	}
+	n.pongs = nil
+
+	if !n.closed {
+		go n.reconnect()
+	}
+}
+
+func (n *NATS) reconnect() {
+	delay := 100 * time.Millisecond
+	for {
+		time.Sleep(delay)
+
+		n.mu.Lock()
+		if n.closed || n.conn != nil {
+			n.mu.Unlock()
+			return
+		}
+		err := n.connect()
+		n.mu.Unlock()
+		if err == nil {
+			return
+		}
+
+		log.Printf("broker: reconnect to %s failed: %v", n.addr, err)
+		if delay *= 2; delay > natsMaxReconnectDelay {
+			delay = natsMaxReconnectDelay
+		}
+	}
+}
+
+func (n *NATS) readLoop(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			n.drop(conn, err)
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "PING":
+			n.mu.Lock()
+			if n.conn == conn {
+				n.w.WriteString("PONG\r\n")
+				if err := n.w.Flush(); err != nil {
+					n.dropLocked(conn, err)
+				}
+			}
+			n.mu.Unlock()
+		case line == "PONG":
+			n.resolvePong(conn)
+		case strings.HasPrefix(line, "-ERR"):
+			n.drop(conn, fmt.Errorf("broker: %s", line))
+			return
+		case strings.HasPrefix(line, "MSG "):
+			if err := n.deliver(line, r); err != nil {
+				n.drop(conn, err)
+				return
+			}
+		}
+	}
+}
+
+// Разбор MSG <subject> <sid> [reply-to] <size> и вызов обработчика подписки
+func (n *NATS) deliver(line string, r *bufio.Reader) error {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return fmt.Errorf("broker: malformed %q", line)
+	}
+
+	sid, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return fmt.Errorf("broker: malformed %q", line)
+	}
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return fmt.Errorf("broker: malformed %q", line)
+	}
+
+	payload := make([]byte, size+2)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	sub, ok := n.subs[sid]
+	n.mu.Unlock()
+	if ok {
+		sub.handler(payload[:size])
+	}
+	return nil
+}
+
+// PONG отвечает на самый ранний неотвеченный PING соединения conn: сервер отвечает
+// по порядку, а Publish пишет PUB с PING и встаёт в очередь под одной блокировкой.
+// PONG уже закрытого соединения не относится к ожидающим Publish нового.
+func (n *NATS) resolvePong(conn net.Conn) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn != conn || len(n.pongs) == 0 {
+		return
+	}
+	pong := n.pongs[0]
+	n.pongs = n.pongs[1:]
+	pong <- nil
+}
+
+func (n *NATS) drop(conn net.Conn, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.dropLocked(conn, err)
+}
@@ -0,0 +1,169 @@
+package broker
+
+import (
+	"context"
+	"em-test/broker/natstest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestNATS(t *testing.T, srv *natstest.Server) *NATS {
+	t.Helper()
+
+	n, err := NewNATS(srv.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+func newTestServer(t *testing.T) *natstest.Server {
+	t.Helper()
+
+	srv, err := natstest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func subscribe(t *testing.T, n *NATS, subject string) <-chan string {
+	t.Helper()
+
+	received := make(chan string, 100)
+	if _, err := n.Subscribe(subject, func(data []byte) { received <- string(data) }); err != nil {
+		t.Fatal(err)
+	}
+	return received
+}
+
+func publish(t *testing.T, n *NATS, subject, data string) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Publish(ctx, subject, []byte(data)); err != nil {
+		t.Fatalf("Publish(%q): %v", data, err)
+	}
+}
+
+func expect(t *testing.T, received <-chan string, want string) {
+	t.Helper()
+
+	select {
+	case got := <-received:
+		if got != want {
+			t.Errorf("received %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%q was not received", want)
+	}
+}
+
+func TestNATSPublishSubscribe(t *testing.T) {
+	srv := newTestServer(t)
+	publisher, subscriber := newTestNATS(t, srv), newTestNATS(t, srv)
+
+	received := subscribe(t, subscriber, "events")
+	other := subscribe(t, subscriber, "other")
+	// PONG на PING после SUB означает, что сервер уже обработал подписку
+	publish(t, subscriber, "sync", "")
+
+	publish(t, publisher, "events", "событие 1")
+	publish(t, publisher, "events", "событие 2")
+	expect(t, received, "событие 1")
+	expect(t, received, "событие 2")
+
+	select {
+	case got := <-other:
+		t.Errorf("other subject received %q", got)
+	default:
+	}
+}
+
+// Публикации, ждущие PONG одновременно, получают подтверждения по порядку
+func TestNATSConcurrentPublish(t *testing.T) {
+	srv := newTestServer(t)
+	n := newTestNATS(t, srv)
+	received := subscribe(t, n, "events")
+
+	const count = 50
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		go func() {
+			errs <- n.Publish(context.Background(), "events", []byte("x"))
+		}()
+	}
+	for i := 0; i < count; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	for i := 0; i < count; i++ {
+		expect(t, received, "x")
+	}
+}
+
+// После обрыва соединение и подписки восстанавливаются
+func TestNATSReconnect(t *testing.T) {
+	srv := newTestServer(t)
+	publisher, subscriber := newTestNATS(t, srv), newTestNATS(t, srv)
+
+	received := subscribe(t, subscriber, "events")
+	publish(t, subscriber, "sync", "")
+	publish(t, publisher, "events", "до обрыва")
+	expect(t, received, "до обрыва")
+
+	srv.DropClients()
+
+	// Подписчик переподключается в фоне; до этого сообщения до него не доходят
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err := publisher.Publish(context.Background(), "events", []byte("после обрыва")); err == nil {
+			select {
+			case got := <-received:
+				if got != "после обрыва" {
+					t.Fatalf("received %q", got)
+				}
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subscription was not restored")
+		}
+	}
+}
+
+// -ERR не привязан к команде: ожидающий Publish получает ошибку, а соединение
+// переустанавливается и следующие публикации проходят
+func TestNATSErrorFailsPendingPublish(t *testing.T) {
+	srv := newTestServer(t)
+	srv.Deny("forbidden")
+	n := newTestNATS(t, srv)
+	received := subscribe(t, n, "events")
+
+	err := n.Publish(context.Background(), "forbidden", []byte("x"))
+	if err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Fatalf("Publish to a denied subject: err = %v, want a permissions violation", err)
+	}
+
+	publish(t, n, "events", "после ошибки")
+	expect(t, received, "после ошибки")
+}
+
+func TestNATSClosed(t *testing.T) {
+	srv := newTestServer(t)
+	n := newTestNATS(t, srv)
+	n.Close()
+
+	if err := n.Publish(context.Background(), "events", nil); err != errClosed {
+		t.Errorf("Publish after Close: err = %v, want errClosed", err)
+	}
+	if _, err := n.Subscribe("events", func([]byte) {}); err != errClosed {
+		t.Errorf("Subscribe after Close: err = %v, want errClosed", err)
+	}
+}
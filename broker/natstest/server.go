@@ -0,0 +1,199 @@
+package natstest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Встраиваемая замена сервера NATS для проверки адаптера без внешнего сервера.
+// Поддерживает CONNECT, PING/PONG, PUB, SUB и UNSUB с точным совпадением темы.
+type Server struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	denied  map[string]bool
+}
+
+type client struct {
+	conn net.Conn
+
+	mu   sync.Mutex
+	w    *bufio.Writer
+	subs map[string]string
+}
+
+// Запуск сервера на свободном локальном порту
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{ln: ln, clients: make(map[*client]struct{}), denied: make(map[string]bool)}
+	go s.accept()
+	return s, nil
+}
+
+// Адрес для broker.NewNATS
+func (s *Server) URL() string {
+	return "nats://" + s.ln.Addr().String()
+}
+
+// Остановка сервера с закрытием всех соединений
+func (s *Server) Close() error {
+	err := s.ln.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		c.conn.Close()
+	}
+	return err
+}
+
+// Разрыв всех клиентских соединений без остановки сервера
+func (s *Server) DropClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		c.conn.Close()
+	}
+}
+
+// Запрет публикации в тему: как и настоящий сервер, он отвечает -ERR
+// о нарушении прав и не закрывает соединение
+func (s *Server) Deny(subject string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.denied[subject] = true
+}
+
+func (s *Server) accept() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &client{conn: conn, w: bufio.NewWriter(conn), subs: make(map[string]string)}
+		s.mu.Lock()
+		s.clients[c] = struct{}{}
+		s.mu.Unlock()
+
+		go s.serve(c)
+	}
+}
+
+func (s *Server) serve(c *client) {
+	defer func() {
+		c.conn.Close()
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+	}()
+
+	c.send("INFO {\"server_id\":\"natstest\",\"version\":\"0.0.0\",\"max_payload\":1048576}\r\n", nil)
+
+	r := bufio.NewReader(c.conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "CONNECT", "PONG":
+		case "PING":
+			c.send("PONG\r\n", nil)
+		case "SUB":
+			// SUB <subject> [queue group] <sid>
+			if len(fields) < 3 {
+				c.send("-ERR 'Invalid Subscription'\r\n", nil)
+				return
+			}
+			c.mu.Lock()
+			c.subs[fields[len(fields)-1]] = fields[1]
+			c.mu.Unlock()
+		case "UNSUB":
+			if len(fields) < 2 {
+				c.send("-ERR 'Invalid Subscription'\r\n", nil)
+				return
+			}
+			c.mu.Lock()
+			delete(c.subs, fields[1])
+			c.mu.Unlock()
+		case "PUB":
+			// PUB <subject> [reply-to] <size>
+			if len(fields) < 3 {
+				c.send("-ERR 'Invalid Publish'\r\n", nil)
+				return
+			}
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				c.send("-ERR 'Invalid Publish'\r\n", nil)
+				return
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			s.mu.Lock()
+			denied := s.denied[fields[1]]
+			s.mu.Unlock()
+			if denied {
+				c.send(fmt.Sprintf("-ERR 'Permissions Violation for Publish to %s'\r\n", fields[1]), nil)
+				continue
+			}
+			s.route(fields[1], payload[:size])
+		default:
+			c.send("-ERR 'Unknown Protocol Operation'\r\n", nil)
+			return
+		}
+	}
+}
+
+func (s *Server) route(subject string, payload []byte) {
+	s.mu.Lock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		c.mu.Lock()
+		var sids []string
+		for sid, subSubject := range c.subs {
+			if subSubject == subject {
+				sids = append(sids, sid)
+			}
+		}
+		c.mu.Unlock()
+
+		for _, sid := range sids {
+			c.send(fmt.Sprintf("MSG %s %s %d\r\n", subject, sid, len(payload)), payload)
+		}
+	}
+}
+
+func (c *client) send(line string, payload []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.w.WriteString(line)
+	if payload != nil {
+		c.w.Write(payload)
+		c.w.WriteString("\r\n")
+	}
+	c.w.Flush()
+}
@@ -1,10 +1,19 @@
 package config
 
 import (
+	"em-test/broker"
+	"em-test/events"
 	"em-test/models"
+	"em-test/search"
+	"em-test/storage"
+	"em-test/webhooks"
+	"em-test/workflow"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
@@ -13,6 +22,32 @@ import (
 
 var DB *gorm.DB
 
+var TaskWorkflow = workflow.Default()
+
+// Запускать таймеры по задаче могут только назначенные на неё пользователи
+var RestrictTimersToAssignees bool
+
+// Максимальная длительность таймера, после которой он останавливается автоматически; 0 отключает ограничение
+var TimerMaxDuration = 12 * time.Hour
+
+// Хранилище вложений задач
+var Storage storage.Store
+
+// Поток событий для подписчиков /events этого процесса
+var Events = events.NewHub()
+
+// Брокер, через который события из outbox доходят до всех реплик
+var Broker broker.Broker = broker.NewMemory()
+
+// Тема брокера для событий
+var BrokerSubject = "em-test.events"
+
+// Максимальный размер вложения в байтах
+var AttachmentMaxBytes int64 = 10 << 20
+
+// Origin страниц других сайтов, которым разрешено открывать /events/ws; страницы самого API разрешены всегда
+var EventsAllowedOrigins []string
+
 func InitDB() {
 	var err error
 
@@ -39,10 +74,124 @@ func InitDB() {
 
 	fmt.Println("Database connected successfully")
 
-	err = DB.AutoMigrate(&models.User{}, &models.Task{}, &models.TaskLog{})
+	err = DB.AutoMigrate(&models.User{}, &models.Task{}, &models.TaskLog{}, &models.Client{}, &models.Project{}, &models.TaskTransition{}, &models.TaskAssignee{}, &models.Tag{}, &models.TaskTag{}, &models.TaskLogTag{}, &models.TaskDependency{}, &models.TaskTemplate{}, &models.TaskOccurrence{}, &models.Comment{}, &models.CommentMention{}, &models.Attachment{}, &models.Rate{}, &models.Invoice{}, &models.InvoiceLine{}, &models.InvoiceSequence{}, &models.Timesheet{}, &models.PeriodLock{}, &models.WorkSchedule{}, &models.Holiday{}, &models.Absence{}, &models.RoundingPolicy{}, &models.Webhook{}, &models.WebhookDelivery{}, &models.OutboxEvent{}, &models.OutboxRelay{})
     if err != nil {
         log.Fatalf("failed to migrate database: %v", err)
     }
 
+	// Задачам, созданным до появления статусов, назначается начальный статус процесса
+	err = DB.Model(&models.Task{}).Where("status = ?", "").UpdateColumn("status", TaskWorkflow.Initial).Error
+	if err != nil {
+		log.Fatalf("failed to backfill task statuses: %v", err)
+	}
+
+	// Правило на проект и одно общее правило; NULL в обычном уникальном индексе не сравнивается
+	err = DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_rounding_policy_scope ON rounding_policies ((COALESCE(project_id, 0)))").Error
+	if err != nil {
+		log.Fatalf("failed to create rounding policy scope index: %v", err)
+	}
+
+	err = search.Migrate(DB)
+	if err != nil {
+		log.Fatalf("failed to migrate search indexes: %v", err)
+	}
+
   fmt.Println("Database migrated successfully")
-}
\ No newline at end of file
+}
+
+// Загрузка процесса статусов задач из файла TASK_WORKFLOW_FILE, если он задан.
+// Вызывается до InitDB, которая проставляет начальный статус старым задачам.
+func InitWorkflow() {
+	path := os.Getenv("TASK_WORKFLOW_FILE")
+	if path == "" {
+		return
+	}
+
+	w, err := workflow.Load(path)
+	if err != nil {
+		log.Fatalf("failed to load task workflow: %v", err)
+	}
+
+	TaskWorkflow = w
+	fmt.Println("Task workflow loaded from", path)
+}
+
+// Чтение прочих настроек сервиса из окружения
+func InitSettings() {
+	RestrictTimersToAssignees = os.Getenv("RESTRICT_TIMERS_TO_ASSIGNEES") == "true"
+	webhooks.AllowPrivateNetworks = os.Getenv("WEBHOOKS_ALLOW_PRIVATE_NETWORKS") == "true"
+
+	if maxDuration := os.Getenv("TIMER_MAX_DURATION"); maxDuration != "" {
+		var err error
+		TimerMaxDuration, err = time.ParseDuration(maxDuration)
+		if err != nil || TimerMaxDuration < 0 {
+			log.Fatalf("invalid TIMER_MAX_DURATION: %s", maxDuration)
+		}
+	}
+
+	// Список через запятую, например https://app.example.com,https://admin.example.com
+	for _, origin := range strings.Split(os.Getenv("EVENTS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			EventsAllowedOrigins = append(EventsAllowedOrigins, strings.TrimRight(origin, "/"))
+		}
+	}
+}
+
+// Выбор хранилища вложений: STORAGE_DRIVER=local (каталог STORAGE_DIR) или s3
+func InitStorage() {
+	var err error
+
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		Storage, err = storage.NewLocal(dir)
+	case "s3":
+		Storage, err = storage.NewS3(
+			os.Getenv("S3_ENDPOINT"),
+			os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_REGION"),
+			os.Getenv("S3_ACCESS_KEY"),
+			os.Getenv("S3_SECRET_KEY"),
+		)
+	default:
+		err = fmt.Errorf("unknown storage driver %q", driver)
+	}
+	if err != nil {
+		log.Fatalf("failed to init attachment storage: %v", err)
+	}
+
+	if maxBytes := os.Getenv("ATTACHMENT_MAX_BYTES"); maxBytes != "" {
+		AttachmentMaxBytes, err = strconv.ParseInt(maxBytes, 10, 64)
+		if err != nil || AttachmentMaxBytes < 1 {
+			log.Fatalf("invalid ATTACHMENT_MAX_BYTES: %s", maxBytes)
+		}
+	}
+}
+
+// Выбор брокера событий: BROKER_DRIVER=memory (только этот процесс) или nats (NATS_URL).
+// Для нескольких реплик нужен nats, иначе подписчики /events видят только события своей реплики.
+func InitBroker() {
+	if subject := os.Getenv("BROKER_SUBJECT"); subject != "" {
+		BrokerSubject = subject
+	}
+
+	var err error
+	switch driver := os.Getenv("BROKER_DRIVER"); driver {
+	case "", "memory":
+		Broker = broker.NewMemory()
+	case "nats":
+		url := os.Getenv("NATS_URL")
+		if url == "" {
+			url = "nats://localhost:4222"
+		}
+		Broker, err = broker.NewNATS(url)
+	default:
+		err = fmt.Errorf("unknown broker driver %q", driver)
+	}
+	if err != nil {
+		log.Fatalf("failed to init event broker: %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"em-test/query"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// Поля отсутствия, доступные для фильтрации и сортировки
+var absenceListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Type: query.Int},
+		"user_id":    {Column: "user_id", Type: query.Int},
+		"type":       {Column: "type", Type: query.String},
+		"start_date": {Column: "start_date", Type: query.Time},
+		"end_date":   {Column: "end_date", Type: query.Time},
+		"created_at": {Column: "created_at", Type: query.Time},
+	},
+	DefaultSort: "-start_date",
+}
+
+// Получение списка отсутствий
+// @Summary Get all absences
+// @Description Get a paginated list of absences. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
+// @Tags absences
+// @Accept json
+// @Produce json
+// @Param user_id query int false "User ID"
+// @Param type query string false "Absence type" Enums(vacation, sick_leave, other)
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -start_date)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.Absence]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /absences [get]
+func GetAbsencesHandler(c *gin.Context) {
+	params, err := query.Parse(c.Request.URL.Query(), absenceListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var absences []models.Absence
+	total, err := params.Find(config.DB.Model(&models.Absence{}), &absences)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(absences, total, params, c.Request.URL))
+}
+
+// Получение отсутствия по id
+// @Summary Get absence by ID
+// @Description Get a single absence by its ID
+// @Tags absences
+// @Accept json
+// @Produce json
+// @Param id path int true "Absence ID"
+// @Param If-None-Match header string false "ETag of a cached version"
+// @Success 200 {object} models.Absence
+// @Header 200 {string} ETag "Resource version"
+// @Success 304
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /absences/{id} [get]
+func GetAbsenceHandler(c *gin.Context) {
+	id := c.Param("id")
+	var absence models.Absence
+
+	result := config.DB.First(&absence, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Absence not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if notModified(c, absence.Version) {
+		return
+	}
+
+	setETag(c, absence.Version)
+	c.JSON(http.StatusOK, absence)
+}
+
+// Создание отсутствия
+// @Summary Create an absence
+// @Description Record a vacation, sick leave or other absence from start_date to end_date inclusive. The time of day is ignored; absences of a user cannot overlap.
+// @Tags absences
+// @Accept json
+// @Produce json
+// @Param absence body models.Absence true "Absence JSON"
+// @Success 201 {object} models.Absence
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /absences [post]
+func CreateAbsenceHandler(c *gin.Context, validate *validator.Validate) {
+	var absence models.Absence
+
+	if err := c.ShouldBindJSON(&absence); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&absence); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if !recordExists(c, &models.User{}, absence.UserID, "User not found") {
+		return
+	}
+
+	absence.StartDate = startOfDay(absence.StartDate)
+	absence.EndDate = startOfDay(absence.EndDate)
+	if absenceOverlaps(c, absence) {
+		return
+	}
+
+	absence.Version = 1
+	result := config.DB.Create(&absence)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+
+	setETag(c, absence.Version)
+	c.JSON(http.StatusCreated, absence)
+}
+
+// Изменение отсутствия
+// @Summary Update an absence
+// @Description Update an absence by ID
+// @Tags absences
+// @Accept json
+// @Produce json
+// @Param id path int true "Absence ID"
+// @Param absence body models.Absence true "Absence data"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.Absence
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /absences/{id} [put]
+func UpdateAbsenceHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var absence models.Absence
+
+	result := config.DB.First(&absence, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Absence not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, absence.Version) {
+		return
+	}
+
+	absenceID, version, createdAt := absence.ID, absence.Version, absence.CreatedAt
+
+	if err := c.ShouldBindJSON(&absence); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&absence); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if !recordExists(c, &models.User{}, absence.UserID, "User not found") {
+		return
+	}
+
+	absence.ID = absenceID
+	absence.CreatedAt = createdAt
+	absence.StartDate = startOfDay(absence.StartDate)
+	absence.EndDate = startOfDay(absence.EndDate)
+	if absenceOverlaps(c, absence) {
+		return
+	}
+
+	absence.Version = version + 1
+
+	result = config.DB.Select("*").Where("version = ?", version).Save(&absence)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	setETag(c, absence.Version)
+	c.JSON(http.StatusOK, absence)
+}
+
+// Удаление отсутствия
+// @Summary Delete an absence
+// @Description Delete an absence by ID
+// @Tags absences
+// @Accept json
+// @Produce json
+// @Param id path int true "Absence ID"
+// @Param If-Match header string false "Expected ETag"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /absences/{id} [delete]
+func DeleteAbsenceHandler(c *gin.Context) {
+	id := c.Param("id")
+	var absence models.Absence
+
+	result := config.DB.First(&absence, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Absence not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, absence.Version) {
+		return
+	}
+
+	result = config.DB.Where("version = ?", absence.Version).Delete(&absence)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Ответ 409, если отсутствие пересекается с другим отсутствием пользователя
+func absenceOverlaps(c *gin.Context, absence models.Absence) bool {
+	var count int64
+
+	err := config.DB.Model(&models.Absence{}).
+		Where("user_id = ? AND id <> ? AND start_date <= ? AND end_date >= ?", absence.UserID, absence.ID, absence.EndDate, absence.StartDate).
+		Count(&count).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return true
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Absence overlaps another absence of the user"})
+		return true
+	}
+
+	return false
+}
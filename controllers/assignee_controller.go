@@ -0,0 +1,368 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"em-test/query"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Получение исполнителей задачи
+// @Summary Get task assignees
+// @Description Get users assigned to a task
+// @Tags assignees
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {array} models.User
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/{id}/assignees [get]
+func GetTaskAssigneesHandler(c *gin.Context) {
+	id := c.Param("id")
+	var task models.Task
+
+	result := config.DB.First(&task, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	users, err := taskAssignees(task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// Назначение пользователей на задачу
+// @Summary Assign users to a task
+// @Description Assign one or more users to a task. Already assigned users are ignored.
+// @Tags assignees
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param assignees body models.AssigneesRequest true "Users to assign"
+// @Success 200 {array} models.User
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/{id}/assignees [post]
+func AddTaskAssigneesHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var task models.Task
+
+	result := config.DB.First(&task, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	var request models.AssigneesRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&request); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	assignees := make([]models.TaskAssignee, 0, len(request.UserIDs))
+	for _, userID := range request.UserIDs {
+		if !recordExists(c, &models.User{}, userID, "User not found") {
+			return
+		}
+		assignees = append(assignees, models.TaskAssignee{TaskID: task.ID, UserID: userID})
+	}
+
+	result = config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&assignees)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+
+	users, err := taskAssignees(task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// Снятие пользователя с задачи
+// @Summary Unassign a user from a task
+// @Description Remove a user from task assignees
+// @Tags assignees
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param user_id path int true "User ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/{id}/assignees/{user_id} [delete]
+func RemoveTaskAssigneeHandler(c *gin.Context) {
+	result := config.DB.Where("task_id = ? AND user_id = ?", c.Param("id"), c.Param("user_id")).Delete(&models.TaskAssignee{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Assignee not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Получение задач, назначенных на пользователя
+// @Summary Get user's assigned tasks
+// @Description Get a paginated list of tasks assigned to the user. Supports the same filters as /tasks.
+// @Tags assignees
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param status query string false "Status"
+// @Param tag query []string false "Tags the task must have" collectionFormat(multi)
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -created_at,title)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.Task]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/tasks [get]
+func GetUserTasksHandler(c *gin.Context) {
+	id := c.Param("id")
+	var user models.User
+
+	result := config.DB.First(&user, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	params, err := query.Parse(c.Request.URL.Query(), taskListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	assigned := config.DB.Model(&models.TaskAssignee{}).Select("task_id").Where("user_id = ?", user.ID)
+
+	db := filterByTags(c, config.DB.Model(&models.Task{}).Where("id IN (?)", assigned), "task_tags", "task_id")
+
+	var tasks []models.Task
+	total, err := params.Find(db, &tasks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := attachTaskTags(tasks); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(tasks, total, params, c.Request.URL))
+}
+
+// Загрузка пользователей: открытые назначенные задачи и время по ним за неделю
+// @Summary Get users' workload
+// @Description Get open (non-terminal) tasks assigned to each user and the time spent on them during the week. Running timers are counted up to now.
+// @Tags assignees
+// @Accept json
+// @Produce json
+// @Param date query string false "Any date within the week (YYYY-MM-DD), defaults to today"
+// @Param user_id query int false "User ID"
+// @Param tz query string false "Time zone of the week (e.g. Asia/Vladivostok), defaults to the user's time zone or UTC"
+// @Success 200 {object} models.WorkloadReport
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /workload [get]
+func GetWorkloadHandler(c *gin.Context) {
+	var userID uint64
+	userIDStr := c.Query("user_id")
+	if userIDStr != "" {
+		var err error
+		userID, err = strconv.ParseUint(userIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid user_id format"})
+			return
+		}
+	}
+
+	loc, ok := reportLocation(c, uint(userID))
+	if !ok {
+		return
+	}
+
+	date := time.Now().In(loc)
+	if dateStr := c.Query("date"); dateStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", dateStr, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid date format"})
+			return
+		}
+		date = parsed
+	}
+
+	weekStart := startOfWeek(date)
+	weekEnd := weekStart.AddDate(0, 0, 7)
+
+	var assignments []struct {
+		UserID uint
+		TaskID uint
+		Title  string
+		Status string
+	}
+
+	db := config.DB.Table("task_assignees").
+		Select("task_assignees.user_id, tasks.id AS task_id, tasks.title, tasks.status").
+		Joins("JOIN tasks ON tasks.id = task_assignees.task_id")
+	if len(config.TaskWorkflow.Terminal) > 0 {
+		db = db.Where("tasks.status NOT IN ?", config.TaskWorkflow.Terminal)
+	}
+	if userIDStr != "" {
+		db = db.Where("task_assignees.user_id = ?", userID)
+	}
+	if err := db.Order("tasks.id").Scan(&assignments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	type assignment struct{ userID, taskID uint }
+	workloads := make(map[uint]*models.Workload)
+	taskIndex := make(map[assignment]int)
+	var userIDs, taskIDs []uint
+
+	for _, a := range assignments {
+		workload, ok := workloads[a.UserID]
+		if !ok {
+			workload = &models.Workload{UserID: a.UserID, OpenTasks: []models.WorkloadTask{}}
+			workloads[a.UserID] = workload
+			userIDs = append(userIDs, a.UserID)
+		}
+		taskIndex[assignment{a.UserID, a.TaskID}] = len(workload.OpenTasks)
+		workload.OpenTasks = append(workload.OpenTasks, models.WorkloadTask{TaskID: a.TaskID, Title: a.Title, Status: a.Status})
+		taskIDs = append(taskIDs, a.TaskID)
+	}
+
+	report := models.WorkloadReport{WeekStart: weekStart, WeekEnd: weekEnd, Users: []models.Workload{}}
+	if len(workloads) == 0 {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	var taskLogs []models.TaskLog
+	result := config.DB.Where("user_id IN ? AND task_id IN ?", userIDs, taskIDs).
+		Where("start_time < ? AND (end_time > ? OR end_time = ?)", weekEnd.UTC(), weekStart.UTC(), time.Time{}).
+		Find(&taskLogs)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+
+	taskMinutes := make(map[assignment]int)
+	for _, log := range taskLogs {
+		key := assignment{log.UserID, log.TaskID}
+		if _, ok := taskIndex[key]; ok {
+			taskMinutes[key] += overlapMinutes(log, weekStart, weekEnd)
+		}
+	}
+
+	var users []models.User
+	if err := config.DB.Find(&users, userIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	for _, user := range users {
+		workloads[user.ID].Name = user.Surname + " " + user.Name
+	}
+
+	for _, userID := range userIDs {
+		workload := workloads[userID]
+
+		total := 0
+		for i := range workload.OpenTasks {
+			minutes := taskMinutes[assignment{userID, workload.OpenTasks[i].TaskID}]
+			workload.OpenTasks[i].Hours = minutes / 60
+			workload.OpenTasks[i].Minutes = minutes % 60
+			total += minutes
+		}
+		workload.Hours = total / 60
+		workload.Minutes = total % 60
+
+		report.Users = append(report.Users, *workload)
+	}
+
+	sort.Slice(report.Users, func(i, j int) bool {
+		return report.Users[i].UserID < report.Users[j].UserID
+	})
+
+	c.JSON(http.StatusOK, report)
+}
+
+func taskAssignees(taskID uint) ([]models.User, error) {
+	users := []models.User{}
+	assigned := config.DB.Model(&models.TaskAssignee{}).Select("user_id").Where("task_id = ?", taskID)
+
+	err := config.DB.Where("id IN (?)", assigned).Order("id").Find(&users).Error
+	return users, err
+}
+
+// Понедельник недели, в которую попадает дата
+func startOfWeek(date time.Time) time.Time {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// Минуты лога, попадающие в период from..to; незавершённый лог длится до текущего момента
+func overlapMinutes(log models.TaskLog, from, to time.Time) int {
+	start, end := log.StartTime, log.EndTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return int(end.Sub(start).Minutes())
+}
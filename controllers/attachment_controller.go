@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"em-test/config"
+	"em-test/models"
+	"em-test/query"
+	"em-test/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Запас на заголовки multipart сверх максимального размера файла
+const multipartOverhead = 1 << 20
+
+// Поля вложения, доступные для фильтрации и сортировки
+var attachmentListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":           {Column: "id", Type: query.Int},
+		"file_name":    {Column: "file_name", Type: query.String, DefaultOp: query.Contains},
+		"content_type": {Column: "content_type", Type: query.String},
+		"size":         {Column: "size", Type: query.Int},
+		"uploader_id":  {Column: "uploader_id", Type: query.Int},
+		"created_at":   {Column: "created_at", Type: query.Time},
+	},
+	DefaultSort: "created_at",
+}
+
+// Получение вложений задачи
+// @Summary Get task attachments
+// @Description Get a paginated list of files attached to a task
+// @Tags attachments
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param file_name query string false "File name contains"
+// @Param content_type query string false "Content type"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -size)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.Attachment]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/{id}/attachments [get]
+func GetTaskAttachmentsHandler(c *gin.Context) {
+	id := c.Param("id")
+	var task models.Task
+
+	result := config.DB.First(&task, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	params, err := query.Parse(c.Request.URL.Query(), attachmentListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var attachments []models.Attachment
+	total, err := params.Find(config.DB.Model(&models.Attachment{}).Where("task_id = ?", task.ID), &attachments)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(attachments, total, params, c.Request.URL))
+}
+
+// Загрузка вложения
+// @Summary Attach a file to a task
+// @Description Upload a file as multipart/form-data. The content type is detected from the file content, the declared one is ignored.
+// @Tags attachments
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param file formData file true "File"
+// @Param user_id formData int false "Uploader ID"
+// @Success 201 {object} models.Attachment
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/{id}/attachments [post]
+func UploadAttachmentHandler(c *gin.Context) {
+	id := c.Param("id")
+	var task models.Task
+
+	result := config.DB.First(&task, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	tooLarge := fmt.Sprintf("File is larger than %d bytes", config.AttachmentMaxBytes)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.AttachmentMaxBytes+multipartOverhead)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{Error: tooLarge})
+		} else {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "file is required"})
+		}
+		return
+	}
+	if fileHeader.Size > config.AttachmentMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{Error: tooLarge})
+		return
+	}
+	if fileHeader.Size == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "File is empty"})
+		return
+	}
+
+	attachment := models.Attachment{
+		TaskID:   task.ID,
+		FileName: filepath.Base(fileHeader.Filename),
+		Size:     fileHeader.Size,
+	}
+
+	if userIDStr := c.PostForm("user_id"); userIDStr != "" {
+		userID, err := strconv.ParseUint(userIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid user_id format"})
+			return
+		}
+		if !recordExists(c, &models.User{}, uint(userID), "User not found") {
+			return
+		}
+		uploaderID := uint(userID)
+		attachment.UploaderID = &uploaderID
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	defer file.Close()
+
+	// Тип определяется по первым 512 байтам, которые затем отдаются в хранилище вместе с остатком
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	attachment.ContentType = http.DetectContentType(head[:n])
+
+	key, err := attachmentKey(task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	attachment.StorageKey = key
+
+	content := io.MultiReader(bytes.NewReader(head[:n]), file)
+	if err := config.Storage.Put(c.Request.Context(), key, content, attachment.Size, attachment.ContentType); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := config.DB.Create(&attachment).Error; err != nil {
+		deleteAttachmentContent(key)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// Получение вложения по id
+// @Summary Get attachment metadata
+// @Description Get attachment metadata by its ID
+// @Tags attachments
+// @Accept json
+// @Produce json
+// @Param id path int true "Attachment ID"
+// @Success 200 {object} models.Attachment
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /attachments/{id} [get]
+func GetAttachmentHandler(c *gin.Context) {
+	id := c.Param("id")
+	var attachment models.Attachment
+
+	result := config.DB.First(&attachment, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Attachment not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, attachment)
+}
+
+// Скачивание содержимого вложения
+// @Summary Download an attachment
+// @Description Stream the attachment content from storage
+// @Tags attachments
+// @Produce octet-stream
+// @Param id path int true "Attachment ID"
+// @Success 200 {file} file
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /attachments/{id}/content [get]
+func DownloadAttachmentHandler(c *gin.Context) {
+	id := c.Param("id")
+	var attachment models.Attachment
+
+	result := config.DB.First(&attachment, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Attachment not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	content, err := config.Storage.Get(c.Request.Context(), attachment.StorageKey)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Attachment content not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// Удаление вложения
+// @Summary Delete an attachment
+// @Description Delete an attachment and its content
+// @Tags attachments
+// @Accept json
+// @Produce json
+// @Param id path int true "Attachment ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /attachments/{id} [delete]
+func DeleteAttachmentHandler(c *gin.Context) {
+	id := c.Param("id")
+	var attachment models.Attachment
+
+	result := config.DB.First(&attachment, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Attachment not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	result = config.DB.Delete(&attachment)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+
+	deleteAttachmentContent(attachment.StorageKey)
+
+	c.Status(http.StatusNoContent)
+}
+
+// Случайный ключ объекта в хранилище, имя файла от клиента в ключ не попадает
+func attachmentKey(taskID uint) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("tasks/%d/%s", taskID, hex.EncodeToString(random)), nil
+}
+
+// Удаление содержимого без ответа клиенту: запись в базе уже удалена или не создана,
+// поэтому ошибка только оставляет лишний объект в хранилище
+func deleteAttachmentContent(key string) {
+	if err := config.Storage.Delete(context.Background(), key); err != nil && err != storage.ErrNotFound {
+		log.Printf("failed to delete attachment content %s: %v", key, err)
+	}
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Максимальная длина периода отчёта по загрузке в днях
+const capacityMaxDays = 366
+
+// Плановое и фактическое время пользователей за период
+// @Summary Get capacity report
+// @Description Compare expected and logged time per user for the period, both dates inclusive. Logged time is split into days in each user's time zone unless tz is given.
+// @Description Scheduled time comes from the user's work schedule; holidays and absences are subtracted to get the expected time.
+// @Description Overtime is logged minus expected time, negative for undertime; utilization is logged time as a percentage of expected time and null when nothing is expected. Running timers are counted up to now.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param start_date query string true "Start Date (YYYY-MM-DD)"
+// @Param end_date query string true "End Date (YYYY-MM-DD)"
+// @Param user_id query int false "User ID"
+// @Param manager_id query int false "Only users reporting to the manager"
+// @Param tz query string false "Time zone of the dates for all users (e.g. Asia/Vladivostok), defaults to each user's time zone"
+// @Success 200 {object} models.CapacityReport
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /capacity [get]
+func GetCapacityHandler(c *gin.Context) {
+	startDateStr := c.Query("start_date")
+	endDateStr := c.Query("end_date")
+
+	if startDateStr == "" || endDateStr == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "start_date and end_date are required"})
+		return
+	}
+
+	startDate, err := time.Parse("2006-01-02", startDateStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid start_date format"})
+		return
+	}
+
+	endDate, err := time.Parse("2006-01-02", endDateStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid end_date format"})
+		return
+	}
+
+	if endDate.Before(startDate) || endDate.After(startDate.AddDate(0, 0, capacityMaxDays-1)) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Period must be from 1 to 366 days long"})
+		return
+	}
+	periodEnd := endDate.AddDate(0, 0, 1)
+
+	// Без tz границы дней считаются в часовом поясе каждого пользователя
+	var fixedLoc *time.Location
+	if c.Query("tz") != "" {
+		loc, ok := reportLocation(c, 0)
+		if !ok {
+			return
+		}
+		fixedLoc = loc
+	}
+
+	db := config.DB.Order("id")
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		userID, err := strconv.ParseUint(userIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid user_id format"})
+			return
+		}
+		db = db.Where("id = ?", userID)
+	}
+	if managerIDStr := c.Query("manager_id"); managerIDStr != "" {
+		managerID, err := strconv.ParseUint(managerIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid manager_id format"})
+			return
+		}
+		db = db.Where("manager_id = ?", managerID)
+	}
+
+	var users []models.User
+	if err := db.Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	report := models.CapacityReport{StartDate: startDate, EndDate: endDate, Users: []models.Capacity{}}
+	if len(users) == 0 {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	userIDs := make([]uint, len(users))
+	for i, user := range users {
+		userIDs[i] = user.ID
+	}
+
+	var schedules []models.WorkSchedule
+	if err := config.DB.Where("user_id IN ?", userIDs).Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	hours := make(map[uint][]float64)
+	for _, schedule := range schedules {
+		hours[schedule.UserID] = schedule.Hours
+	}
+
+	var holidays []models.Holiday
+	if err := config.DB.Where("date >= ? AND date < ?", startDate, periodEnd).Find(&holidays).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	holidayDates := make(map[time.Time]bool)
+	for _, holiday := range holidays {
+		holidayDates[startOfDay(holiday.Date)] = true
+	}
+
+	var absences []models.Absence
+	result := config.DB.Where("user_id IN ? AND start_date < ? AND end_date >= ?", userIDs, periodEnd, startDate).Find(&absences)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	userAbsences := make(map[uint][]models.Absence)
+	for _, absence := range absences {
+		userAbsences[absence.UserID] = append(userAbsences[absence.UserID], absence)
+	}
+
+	periods := make(map[uint][2]time.Time)
+	for _, user := range users {
+		loc := fixedLoc
+		if loc == nil {
+			loc = userLocation(user)
+		}
+		from := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, loc)
+		periods[user.ID] = [2]time.Time{from, time.Date(periodEnd.Year(), periodEnd.Month(), periodEnd.Day(), 0, 0, 0, 0, loc)}
+	}
+
+	// Запас в сутки с обеих сторон покрывает любые часовые пояса
+	var taskLogs []models.TaskLog
+	result = config.DB.Where("user_id IN ?", userIDs).
+		Where("start_time < ? AND (end_time > ? OR end_time = ?)", periodEnd.AddDate(0, 0, 1), startDate.AddDate(0, 0, -1), time.Time{}).
+		Find(&taskLogs)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	logged := make(map[uint]int)
+	for _, log := range taskLogs {
+		period := periods[log.UserID]
+		logged[log.UserID] += overlapMinutes(log, period[0], period[1])
+	}
+
+	for _, user := range users {
+		week, ok := hours[user.ID]
+		if !ok {
+			week = defaultWorkHours
+		}
+
+		capacity := models.Capacity{UserID: user.ID, Name: user.Surname + " " + user.Name, LoggedMinutes: logged[user.ID]}
+
+		for day := startDate; day.Before(periodEnd); day = day.AddDate(0, 0, 1) {
+			minutes := int(math.Round(week[(int(day.Weekday())+6)%7] * 60))
+			capacity.ScheduledMinutes += minutes
+
+			switch {
+			case holidayDates[day]:
+				capacity.HolidayMinutes += minutes
+			case absentOn(userAbsences[user.ID], day):
+				capacity.AbsenceMinutes += minutes
+			default:
+				capacity.ExpectedMinutes += minutes
+			}
+		}
+
+		capacity.OvertimeMinutes = capacity.LoggedMinutes - capacity.ExpectedMinutes
+		if capacity.ExpectedMinutes > 0 {
+			utilization := math.Round(float64(capacity.LoggedMinutes)/float64(capacity.ExpectedMinutes)*10000) / 100
+			capacity.UtilizationPercent = &utilization
+		}
+
+		report.Users = append(report.Users, capacity)
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func absentOn(absences []models.Absence, day time.Time) bool {
+	for _, absence := range absences {
+		if !day.Before(startOfDay(absence.StartDate)) && !day.After(startOfDay(absence.EndDate)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"em-test/query"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// Поля клиента, доступные для фильтрации и сортировки
+var clientListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Type: query.Int},
+		"name":       {Column: "name", Type: query.String, DefaultOp: query.Contains},
+		"created_at": {Column: "created_at", Type: query.Time},
+		"updated_at": {Column: "updated_at", Type: query.Time},
+	},
+}
+
+// Получение списка клиентов
+// @Summary Get all clients
+// @Description Get a paginated list of clients. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
+// @Tags clients
+// @Accept json
+// @Produce json
+// @Param name query string false "Name contains"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. name)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.Client]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /clients [get]
+func GetClientsHandler(c *gin.Context) {
+	params, err := query.Parse(c.Request.URL.Query(), clientListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var clients []models.Client
+	total, err := params.Find(config.DB.Model(&models.Client{}), &clients)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(clients, total, params, c.Request.URL))
+}
+
+// Получение клиента по id
+// @Summary Get client by ID
+// @Description Get a single client by its ID
+// @Tags clients
+// @Accept json
+// @Produce json
+// @Param id path int true "Client ID"
+// @Param If-None-Match header string false "ETag of a cached version"
+// @Success 200 {object} models.Client
+// @Header 200 {string} ETag "Resource version"
+// @Success 304
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /clients/{id} [get]
+func GetClientHandler(c *gin.Context) {
+	id := c.Param("id")
+	var client models.Client
+
+	result := config.DB.First(&client, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Client not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if notModified(c, client.Version) {
+		return
+	}
+
+	setETag(c, client.Version)
+	c.JSON(http.StatusOK, client)
+}
+
+// Создание нового клиента
+// @Summary Create a new client
+// @Description Create a new client with the input payload
+// @Tags clients
+// @Accept json
+// @Produce json
+// @Param client body models.Client true "Client JSON"
+// @Success 201 {object} models.Client
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /clients [post]
+func CreateClientHandler(c *gin.Context, validate *validator.Validate) {
+	var client models.Client
+
+	if err := c.ShouldBindJSON(&client); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&client); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	client.Version = 1
+	result := config.DB.Create(&client)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+
+	setETag(c, client.Version)
+	c.JSON(http.StatusCreated, client)
+}
+
+// Изменение данных клиента
+// @Summary Update a client
+// @Description Update client details by ID
+// @Tags clients
+// @Accept json
+// @Produce json
+// @Param id path int true "Client ID"
+// @Param client body models.Client true "Client data"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.Client
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /clients/{id} [put]
+func UpdateClientHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var client models.Client
+
+	result := config.DB.First(&client, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Client not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, client.Version) {
+		return
+	}
+
+	clientID, version, createdAt := client.ID, client.Version, client.CreatedAt
+
+	if err := c.ShouldBindJSON(&client); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&client); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	client.ID = clientID
+	client.CreatedAt = createdAt
+	client.Version = version + 1
+
+	result = config.DB.Select("*").Where("version = ?", version).Save(&client)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	setETag(c, client.Version)
+	c.JSON(http.StatusOK, client)
+}
+
+// Удаление клиента
+// @Summary Delete a client
+// @Description Delete a client by ID. Clients that still have projects cannot be deleted.
+// @Tags clients
+// @Accept json
+// @Produce json
+// @Param id path int true "Client ID"
+// @Param If-Match header string false "Expected ETag"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /clients/{id} [delete]
+func DeleteClientHandler(c *gin.Context) {
+	id := c.Param("id")
+	var client models.Client
+
+	result := config.DB.First(&client, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Client not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, client.Version) {
+		return
+	}
+
+	var projects int64
+	if err := config.DB.Model(&models.Project{}).Where("client_id = ?", client.ID).Count(&projects).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if projects > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Client has projects"})
+		return
+	}
+
+	result = config.DB.Where("version = ?", client.Version).Delete(&client)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
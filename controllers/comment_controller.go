@@ -0,0 +1,472 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"em-test/query"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// Упоминание пользователя в тексте: @42, но не адрес вида a@42
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\d+)\b`)
+
+// Поля комментария, доступные для фильтрации и сортировки
+var commentListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Type: query.Int},
+		"parent_id":  {Column: "parent_id", Type: query.Int},
+		"author_id":  {Column: "author_id", Type: query.Int},
+		"created_at": {Column: "created_at", Type: query.Time},
+		"updated_at": {Column: "updated_at", Type: query.Time},
+	},
+	DefaultSort: "created_at",
+}
+
+// Получение комментариев задачи
+// @Summary Get task comments
+// @Description Get a paginated list of task comments in chronological order. Replies reference their parent comment via parent_id; deleted comments that have replies are kept with an empty body.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param parent_id query int false "Parent comment ID"
+// @Param author_id query int false "Author ID"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -created_at)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.Comment]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/{id}/comments [get]
+func GetTaskCommentsHandler(c *gin.Context) {
+	id := c.Param("id")
+	var task models.Task
+
+	result := config.DB.First(&task, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	params, err := query.Parse(c.Request.URL.Query(), commentListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var comments []models.Comment
+	total, err := params.Find(config.DB.Model(&models.Comment{}).Where("task_id = ?", task.ID), &comments)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := attachMentions(comments); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(comments, total, params, c.Request.URL))
+}
+
+// Добавление комментария к задаче
+// @Summary Comment on a task
+// @Description Add a comment or, with parent_id, a reply to another comment of the same task. Users mentioned as @userId are stored with the comment.
+// @Description The task version is incremented because its comment count changes.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param comment body models.Comment true "Comment JSON"
+// @Success 201 {object} models.Comment
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/{id}/comments [post]
+func CreateCommentHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var task models.Task
+
+	result := config.DB.First(&task, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	var comment models.Comment
+
+	if err := c.ShouldBindJSON(&comment); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&comment); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if !recordExists(c, &models.User{}, comment.AuthorID, "User not found") {
+		return
+	}
+
+	if comment.ParentID != nil {
+		var parents int64
+		if err := config.DB.Model(&models.Comment{}).Where("id = ? AND task_id = ?", *comment.ParentID, task.ID).Count(&parents).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		if parents == 0 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Parent comment not found"})
+			return
+		}
+	}
+
+	mentions, err := parseMentions(comment.Body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	comment.ID = 0
+	comment.TaskID = task.ID
+	comment.Mentions = mentions
+	comment.Deleted = false
+	comment.Version = 1
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		if err := bumpTaskVersion(tx, comment.TaskID); err != nil {
+			return err
+		}
+		return setCommentMentions(tx, comment.ID, comment.Mentions)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, comment.Version)
+	c.JSON(http.StatusCreated, comment)
+}
+
+// Изменение комментария
+// @Summary Edit a comment
+// @Description Change the comment text. Only the author can edit a comment; mentions are parsed again.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "Comment ID"
+// @Param comment body models.CommentUpdateRequest true "Editing user and new text"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.Comment
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /comments/{id} [put]
+func UpdateCommentHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var comment models.Comment
+
+	result := config.DB.Where("deleted = ?", false).First(&comment, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Comment not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, comment.Version) {
+		return
+	}
+
+	var request models.CommentUpdateRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&request); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if request.UserID != comment.AuthorID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Only the author can edit the comment"})
+		return
+	}
+
+	mentions, err := parseMentions(request.Body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	version := comment.Version
+	comment.Body = request.Body
+	comment.Mentions = mentions
+	comment.Version = version + 1
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Select("*").Where("version = ?", version).Save(&comment)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVersionConflict
+		}
+
+		return setCommentMentions(tx, comment.ID, comment.Mentions)
+	})
+	if err == errVersionConflict {
+		versionConflict(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, comment.Version)
+	c.JSON(http.StatusOK, comment)
+}
+
+// Удаление комментария
+// @Summary Delete a comment
+// @Description Delete a comment. Only the author can delete a comment; a comment with replies is kept as deleted with an empty body so the thread stays intact.
+// @Description The task version is incremented because its comment count changes.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "Comment ID"
+// @Param user_id query int true "Deleting user ID"
+// @Param If-Match header string false "Expected ETag"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /comments/{id} [delete]
+func DeleteCommentHandler(c *gin.Context) {
+	id := c.Param("id")
+	var comment models.Comment
+
+	result := config.DB.Where("deleted = ?", false).First(&comment, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Comment not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Query("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid user_id format"})
+		return
+	}
+
+	if uint(userID) != comment.AuthorID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Only the author can delete the comment"})
+		return
+	}
+
+	if preconditionFailed(c, comment.Version) {
+		return
+	}
+
+	var replies int64
+	if err := config.DB.Model(&models.Comment{}).Where("parent_id = ?", comment.ID).Count(&replies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var result *gorm.DB
+		if replies > 0 {
+			result = tx.Model(&comment).Where("version = ?", comment.Version).
+				Updates(map[string]interface{}{"body": "", "deleted": true, "version": comment.Version + 1})
+		} else {
+			result = tx.Where("version = ?", comment.Version).Delete(&comment)
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVersionConflict
+		}
+		if err := bumpTaskVersion(tx, comment.TaskID); err != nil {
+			return err
+		}
+
+		return tx.Where("comment_id = ?", comment.ID).Delete(&models.CommentMention{}).Error
+	})
+	if err == errVersionConflict {
+		versionConflict(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Число комментариев входит в ответ GET /tasks/{id}, поэтому при его изменении
+// растёт версия задачи и вместе с ней ETag
+func bumpTaskVersion(tx *gorm.DB, taskID uint) error {
+	return tx.Model(&models.Task{}).Where("id = ?", taskID).UpdateColumn("version", gorm.Expr("version + 1")).Error
+}
+
+// Получение комментариев с упоминанием пользователя
+// @Summary Get user's mentions
+// @Description Get a paginated list of comments mentioning the user, newest first
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.Comment]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/mentions [get]
+func GetUserMentionsHandler(c *gin.Context) {
+	id := c.Param("id")
+	var user models.User
+
+	result := config.DB.First(&user, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	spec := commentListSpec
+	spec.DefaultSort = "-created_at"
+
+	params, err := query.Parse(c.Request.URL.Query(), spec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	mentioned := config.DB.Model(&models.CommentMention{}).Select("comment_id").Where("user_id = ?", user.ID)
+
+	var comments []models.Comment
+	total, err := params.Find(config.DB.Model(&models.Comment{}).Where("id IN (?)", mentioned), &comments)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := attachMentions(comments); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(comments, total, params, c.Request.URL))
+}
+
+// Разбор упоминаний @userId; несуществующие пользователи пропускаются
+func parseMentions(body string) ([]uint, error) {
+	mentions := []uint{}
+
+	var ids []uint
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		id, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	if len(ids) == 0 {
+		return mentions, nil
+	}
+
+	err := config.DB.Model(&models.User{}).Where("id IN ?", ids).Order("id").Pluck("id", &mentions).Error
+	return mentions, err
+}
+
+// Замена упоминаний комментария
+func setCommentMentions(tx *gorm.DB, commentID uint, userIDs []uint) error {
+	if err := tx.Where("comment_id = ?", commentID).Delete(&models.CommentMention{}).Error; err != nil {
+		return err
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	mentions := make([]models.CommentMention, len(userIDs))
+	for i, userID := range userIDs {
+		mentions[i] = models.CommentMention{CommentID: commentID, UserID: userID}
+	}
+	return tx.Create(&mentions).Error
+}
+
+// Заполнение поля Mentions у комментариев
+func attachMentions(comments []models.Comment) error {
+	ids := make([]uint, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+
+	var links []models.CommentMention
+	if len(ids) > 0 {
+		if err := config.DB.Where("comment_id IN ?", ids).Order("user_id").Find(&links).Error; err != nil {
+			return err
+		}
+	}
+
+	mentions := make(map[uint][]uint)
+	for _, link := range links {
+		mentions[link.CommentID] = append(mentions[link.CommentID], link.UserID)
+	}
+	for i := range comments {
+		comments[i].Mentions = mentions[comments[i].ID]
+		if comments[i].Mentions == nil {
+			comments[i].Mentions = []uint{}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// Получение задач, блокирующих задачу
+// @Summary Get task blockers
+// @Description Get tasks the given task is blocked by
+// @Tags dependencies
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {array} models.Task
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/{id}/dependencies [get]
+func GetTaskDependenciesHandler(c *gin.Context) {
+	id := c.Param("id")
+	var task models.Task
+
+	result := config.DB.First(&task, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	blockers, err := taskBlockers(task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, blockers)
+}
+
+// Добавление блокирующей задачи
+// @Summary Add a task blocker
+// @Description Mark the task as blocked by another task. Dependencies that would form a cycle are rejected.
+// @Tags dependencies
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param dependency body models.DependencyRequest true "Blocking task"
+// @Success 200 {array} models.Task
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/{id}/dependencies [post]
+func AddTaskDependencyHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var task models.Task
+
+	result := config.DB.First(&task, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	var request models.DependencyRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&request); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if !recordExists(c, &models.Task{}, request.BlockedByID, "Blocking task not found") {
+		return
+	}
+
+	cycle, err := dependencyCycle(task.ID, request.BlockedByID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if cycle {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Dependency would create a cycle"})
+		return
+	}
+
+	dependency := models.TaskDependency{TaskID: task.ID, BlockedByID: request.BlockedByID}
+	result = config.DB.Where(&dependency).FirstOrCreate(&dependency)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+
+	blockers, err := taskBlockers(task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, blockers)
+}
+
+// Удаление блокирующей задачи
+// @Summary Remove a task blocker
+// @Description Remove a "blocked by" dependency between two tasks
+// @Tags dependencies
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param blocked_by_id path int true "Blocking task ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/{id}/dependencies/{blocked_by_id} [delete]
+func RemoveTaskDependencyHandler(c *gin.Context) {
+	result := config.DB.Where("task_id = ? AND blocked_by_id = ?", c.Param("id"), c.Param("blocked_by_id")).Delete(&models.TaskDependency{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Dependency not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func taskBlockers(taskID uint) ([]models.Task, error) {
+	blockers := []models.Task{}
+
+	blockedBy := config.DB.Model(&models.TaskDependency{}).Select("blocked_by_id").Where("task_id = ?", taskID)
+	if err := config.DB.Where("id IN (?)", blockedBy).Order("id").Find(&blockers).Error; err != nil {
+		return nil, err
+	}
+	if err := attachTaskTags(blockers); err != nil {
+		return nil, err
+	}
+
+	return blockers, nil
+}
+
+// Число незавершённых задач, блокирующих задачу
+func openBlockers(taskID uint) (int64, error) {
+	var count int64
+
+	blockedBy := config.DB.Model(&models.TaskDependency{}).Select("blocked_by_id").Where("task_id = ?", taskID)
+	db := config.DB.Model(&models.Task{}).Where("id IN (?)", blockedBy)
+	if len(config.TaskWorkflow.Terminal) > 0 {
+		db = db.Where("status NOT IN ?", config.TaskWorkflow.Terminal)
+	}
+
+	err := db.Count(&count).Error
+	return count, err
+}
+
+// Проверка, что зависимость taskID -> blockedByID замкнёт цикл:
+// обход блокирующих задач в ширину, начиная с blockedByID
+func dependencyCycle(taskID, blockedByID uint) (bool, error) {
+	visited := map[uint]bool{blockedByID: true}
+	frontier := []uint{blockedByID}
+
+	for len(frontier) > 0 {
+		for _, id := range frontier {
+			if id == taskID {
+				return true, nil
+			}
+		}
+
+		var next []uint
+		err := config.DB.Model(&models.TaskDependency{}).Where("task_id IN ?", frontier).Pluck("blocked_by_id", &next).Error
+		if err != nil {
+			return false, err
+		}
+
+		frontier = frontier[:0]
+		for _, id := range next {
+			if !visited[id] {
+				visited[id] = true
+				frontier = append(frontier, id)
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// Проверка родительской задачи: она должна существовать и не быть
+// самой задачей или её подзадачей. При ошибке отвечает 400.
+func validParent(c *gin.Context, taskID, parentID uint) bool {
+	if !recordExists(c, &models.Task{}, parentID, "Parent task not found") {
+		return false
+	}
+
+	visited := make(map[uint]bool)
+	for id := &parentID; id != nil && !visited[*id]; {
+		if *id == taskID {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Task cannot be a subtask of itself or of its subtasks"})
+			return false
+		}
+		visited[*id] = true
+
+		var parent models.Task
+		if err := config.DB.Select("id", "parent_id").First(&parent, *id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				break
+			}
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return false
+		}
+		id = parent.ParentID
+	}
+
+	return true
+}
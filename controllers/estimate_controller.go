@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"em-test/query"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Сравнение оценок задач с фактически затраченным временем
+// @Summary Compare estimates with actual time
+// @Description Get a paginated list of estimated and logged time for tasks that have an estimate, ordered by overrun unless sort is given. Running timers are counted up to now. Supports the same filters and sorting as /tasks.
+// @Tags estimates
+// @Accept json
+// @Produce json
+// @Param project_id query int false "Project ID"
+// @Param status query string false "Status"
+// @Param tag query []string false "Tags the task must have" collectionFormat(multi)
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -created_at,title)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.TaskEstimate]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/estimates [get]
+func GetTaskEstimatesHandler(c *gin.Context) {
+	taskEstimates(c, false)
+}
+
+// Задачи, превысившие оценку
+// @Summary Get tasks over budget
+// @Description Get a paginated list of tasks whose logged time exceeds the estimate, ordered by overrun unless sort is given. Supports the same filters and sorting as /tasks.
+// @Tags estimates
+// @Accept json
+// @Produce json
+// @Param project_id query int false "Project ID"
+// @Param status query string false "Status"
+// @Param tag query []string false "Tags the task must have" collectionFormat(multi)
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -created_at,title)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.TaskEstimate]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/over-budget [get]
+func GetTasksOverBudgetHandler(c *gin.Context) {
+	taskEstimates(c, true)
+}
+
+// Страница оценок; фактическое время суммируется в БД по целым минутам каждого лога
+func taskEstimates(c *gin.Context, overBudgetOnly bool) {
+	params, err := query.Parse(c.Request.URL.Query(), taskListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	actual := config.DB.Model(&models.TaskLog{}).
+		Select("CAST(COALESCE(SUM(FLOOR(EXTRACT(EPOCH FROM (CASE WHEN end_time = ? THEN CAST(? AS timestamptz) ELSE end_time END) - start_time) / 60)), 0) AS bigint)", time.Time{}, time.Now()).
+		Where("task_logs.task_id = tasks.id")
+	// Все столбцы задачи остаются в подзапросе, чтобы по ним можно было сортировать
+	tasks := filterByTags(c, config.DB.Model(&models.Task{}), "task_tags", "task_id")
+	tasks = params.Filter(tasks.
+		Select("tasks.*, id AS task_id, (?) AS actual_minutes", actual).
+		Where("estimate_minutes IS NOT NULL"))
+
+	db := config.DB.Table("(?) AS estimates", tasks)
+	if overBudgetOnly {
+		db = db.Where("actual_minutes > estimate_minutes")
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var estimates []models.TaskEstimate
+	if len(params.Sort) > 0 {
+		db = params.Order(db)
+	} else {
+		db = db.Order("CAST(GREATEST(actual_minutes - estimate_minutes, 0) AS float) / estimate_minutes DESC").Order("task_id")
+	}
+	err = db.Limit(params.PageSize).Offset((params.Page - 1) * params.PageSize).
+		Scan(&estimates).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	for i := range estimates {
+		estimate := &estimates[i]
+		if estimate.ActualMinutes < estimate.EstimateMinutes {
+			estimate.RemainingMinutes = estimate.EstimateMinutes - estimate.ActualMinutes
+		} else {
+			overrun := float64(estimate.ActualMinutes-estimate.EstimateMinutes) / float64(estimate.EstimateMinutes) * 100
+			estimate.OverrunPercent = math.Round(overrun*100) / 100
+		}
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(estimates, total, params, c.Request.URL))
+}
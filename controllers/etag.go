@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"em-test/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ошибка для отката транзакции, если запись изменили между чтением и сохранением
+var errVersionConflict = errors.New("version conflict")
+
+// ETag ресурса строится из его версии
+func etag(version uint) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// Установка заголовка ETag для текущей версии ресурса
+func setETag(c *gin.Context, version uint) {
+	c.Header("ETag", etag(version))
+}
+
+// Ответ 304, если клиент уже имеет актуальную версию (If-None-Match)
+func notModified(c *gin.Context, version uint) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" || !etagMatches(header, version, true) {
+		return false
+	}
+
+	setETag(c, version)
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// Ответ 412, если версия из If-Match не совпадает с текущей. Без If-Match
+// проверки нет: сохранение всё равно условно по версии, прочитанной обработчиком.
+func preconditionFailed(c *gin.Context, version uint) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" || etagMatches(header, version, false) {
+		return false
+	}
+
+	setETag(c, version)
+	versionConflict(c)
+	return true
+}
+
+// Ответ 412, если запись изменили между чтением и сохранением
+func versionConflict(c *gin.Context) {
+	c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{Error: "Resource has been modified"})
+}
+
+// Сравнение списка ETag из заголовка с версией ресурса.
+// Для If-None-Match используется слабое сравнение (префикс W/ игнорируется).
+func etagMatches(header string, version uint, weak bool) bool {
+	current := etag(version)
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == current {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/events"
+	"em-test/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// Интервал комментариев SSE, не дающих прокси закрыть простаивающее соединение
+const eventKeepAlive = 30 * time.Second
+
+// Сколько пропущенных событий можно получить при возобновлении потока
+const eventBacklogLimit = 1000
+
+// Поток событий таймеров и задач (Server-Sent Events)
+// @Summary Stream events
+// @Description Stream timer.started, timer.stopped, task.created, task.updated and user.created events as Server-Sent Events. The event name is the type and the data is a models.Event.
+// @Description Timers cannot be paused in this API, so there are no pause events; a stopped timer has auto_stopped set when it was stopped automatically.
+// @Description To resume after a disconnect pass the last received id in the Last-Event-ID header or last_event_id; missed events are replayed from the last 7 days.
+// @Description If they are older or there are more than 1000 of them, a resync event comes instead and the client should reload its data. Task events have no user, so user_id filters them out.
+// @Description An event can be repeated after a broker failure; clients should skip ids they have already seen.
+// @Tags events
+// @Produce text/event-stream
+// @Param user_id query int false "Only events of the user and their timers"
+// @Param task_id query int false "Only events of the task and its timers"
+// @Param last_event_id query int false "Id of the last received event"
+// @Param Last-Event-ID header int false "Id of the last received event"
+// @Success 200 {object} models.Event
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /events [get]
+func StreamEventsHandler(c *gin.Context) {
+	filter, lastID, ok := eventStreamParams(c)
+	if !ok {
+		return
+	}
+
+	sub := config.Events.Subscribe(filter)
+	defer config.Events.Unsubscribe(sub)
+
+	backlog, err := eventBacklog(filter, lastID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	sent := eventIDs(backlog)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	for _, event := range backlog {
+		if err := writeServerSentEvent(c.Writer, event); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			if sent[event.ID] {
+				continue
+			}
+			if err := writeServerSentEvent(c.Writer, event); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := io.WriteString(c.Writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// Поток событий таймеров и задач через WebSocket
+// @Summary Stream events over WebSocket
+// @Description Upgrade to a WebSocket that sends each models.Event as a JSON text message; messages from the client are ignored.
+// @Description Filters, resuming with last_event_id and the resync event work as in GET /events.
+// @Description Browsers may connect only from pages of the API host or of an origin listed in EVENTS_ALLOWED_ORIGINS; requests without Origin are accepted.
+// @Tags events
+// @Param user_id query int false "Only events of the user and their timers"
+// @Param task_id query int false "Only events of the task and its timers"
+// @Param last_event_id query int false "Id of the last received event"
+// @Success 101
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /events/ws [get]
+func EventsWebSocketHandler(c *gin.Context) {
+	if !allowedOrigin(c.Request) {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Origin not allowed"})
+		return
+	}
+
+	filter, lastID, ok := eventStreamParams(c)
+	if !ok {
+		return
+	}
+
+	sub := config.Events.Subscribe(filter)
+	defer config.Events.Unsubscribe(sub)
+
+	backlog, err := eventBacklog(filter, lastID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// Origin уже проверен allowedOrigin, поэтому Handshake не задан
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		streamWebSocketEvents(ws, sub, backlog)
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// Браузер всегда передаёт Origin, поэтому без него подключается не страница, а
+// обычный клиент. Иначе Origin должен совпадать с хостом API или быть в
+// EVENTS_ALLOWED_ORIGINS — иначе чужая страница прочтёт через браузер пользователя
+// события API, доступного только из внутренней сети.
+func allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	for _, allowed := range config.EventsAllowedOrigins {
+		if strings.EqualFold(allowed, u.Scheme+"://"+u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+func streamWebSocketEvents(ws *websocket.Conn, sub *events.Subscription, backlog []models.Event) {
+	sent := eventIDs(backlog)
+
+	// Чтение нужно только чтобы заметить закрытие соединения клиентом
+	closed := make(chan struct{})
+	go func() {
+		var message string
+		for websocket.Message.Receive(ws, &message) == nil {
+		}
+		close(closed)
+	}()
+
+	for _, event := range backlog {
+		if err := websocket.JSON.Send(ws, event); err != nil {
+			return
+		}
+	}
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			if sent[event.ID] {
+				continue
+			}
+			if err := websocket.JSON.Send(ws, event); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Фильтры и id последнего полученного события из запроса
+func eventStreamParams(c *gin.Context) (events.Filter, uint64, bool) {
+	var filter events.Filter
+
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		userID, err := strconv.ParseUint(userIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid user_id format"})
+			return filter, 0, false
+		}
+		id := uint(userID)
+		filter.UserID = &id
+	}
+
+	if taskIDStr := c.Query("task_id"); taskIDStr != "" {
+		taskID, err := strconv.ParseUint(taskIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid task_id format"})
+			return filter, 0, false
+		}
+		id := uint(taskID)
+		filter.TaskID = &id
+	}
+
+	lastIDStr := c.GetHeader("Last-Event-ID")
+	if lastIDStr == "" {
+		lastIDStr = c.Query("last_event_id")
+	}
+
+	var lastID uint64
+	if lastIDStr != "" {
+		var err error
+		lastID, err = strconv.ParseUint(lastIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid last event id"})
+			return filter, 0, false
+		}
+	}
+
+	return filter, lastID, true
+}
+
+func writeServerSentEvent(w io.Writer, event models.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// Пропущенные после события lastID события из outbox в порядке передачи брокеру.
+// Если событие lastID уже удалено или пропущенных больше eventBacklogLimit, вместо
+// них возвращается одно событие resync. Сюда попадают и события, которые relay
+// передаёт прямо сейчас; их повтор из подписки отбрасывается по id.
+func eventBacklog(filter events.Filter, lastID uint64) ([]models.Event, error) {
+	if lastID == 0 {
+		return nil, nil
+	}
+	resync := []models.Event{{ID: lastID, Type: models.EventResync, Time: time.Now().UTC()}}
+
+	var last models.OutboxEvent
+	result := config.DB.Where("id = ?", lastID).Limit(1).Find(&last)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || last.Sequence == nil {
+		return resync, nil
+	}
+
+	db := config.DB.Where("sequence > ?", *last.Sequence)
+	if filter.UserID != nil {
+		db = db.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.TaskID != nil {
+		db = db.Where("task_id = ?", *filter.TaskID)
+	}
+
+	var rows []models.OutboxEvent
+	if err := db.Order("sequence").Limit(eventBacklogLimit + 1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) > eventBacklogLimit {
+		return resync, nil
+	}
+
+	backlog := make([]models.Event, len(rows))
+	for i, row := range rows {
+		backlog[i] = outboxEvent(row)
+	}
+	return backlog, nil
+}
+
+// Id уже отправленных событий: подписка оформляется до чтения outbox,
+// поэтому событие может прийти и из outbox, и из подписки
+func eventIDs(backlog []models.Event) map[uint64]bool {
+	ids := make(map[uint64]bool, len(backlog))
+	for _, event := range backlog {
+		ids[event.ID] = true
+	}
+	return ids
+}
@@ -0,0 +1,507 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"em-test/money"
+	"em-test/pdf"
+	"em-test/query"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ошибка для отката транзакции, если часть логов попала в другой счёт
+var errLogsInvoiced = errors.New("task logs already invoiced")
+
+// Поля счёта, доступные для фильтрации и сортировки
+var invoiceListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Type: query.Int},
+		"number":     {Column: "number", Type: query.String},
+		"client_id":  {Column: "client_id", Type: query.Int},
+		"user_id":    {Column: "user_id", Type: query.Int},
+		"status":     {Column: "status", Type: query.String},
+		"currency":   {Column: "currency", Type: query.String},
+		"created_at": {Column: "created_at", Type: query.Time},
+	},
+	DefaultSort: "-created_at",
+}
+
+// Получение списка счетов
+// @Summary Get all invoices
+// @Description Get a paginated list of invoices with their lines. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
+// @Tags invoices
+// @Accept json
+// @Produce json
+// @Param client_id query int false "Client ID"
+// @Param user_id query int false "User ID"
+// @Param status query string false "Status" Enums(issued, void)
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -created_at)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.Invoice]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /invoices [get]
+func GetInvoicesHandler(c *gin.Context) {
+	params, err := query.Parse(c.Request.URL.Query(), invoiceListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var invoices []models.Invoice
+	total, err := params.Find(config.DB.Model(&models.Invoice{}).Preload("Lines", orderByID), &invoices)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(invoices, total, params, c.Request.URL))
+}
+
+// Получение счёта по id
+// @Summary Get invoice by ID
+// @Description Get an invoice with its lines as JSON
+// @Tags invoices
+// @Accept json
+// @Produce json
+// @Param id path int true "Invoice ID"
+// @Param If-None-Match header string false "ETag of a cached version"
+// @Success 200 {object} models.Invoice
+// @Header 200 {string} ETag "Resource version"
+// @Success 304
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /invoices/{id} [get]
+func GetInvoiceHandler(c *gin.Context) {
+	id := c.Param("id")
+	var invoice models.Invoice
+
+	result := config.DB.Preload("Lines", orderByID).First(&invoice, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Invoice not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if notModified(c, invoice.Version) {
+		return
+	}
+
+	setETag(c, invoice.Version)
+	c.JSON(http.StatusOK, invoice)
+}
+
+// Выставление счёта
+// @Summary Generate an invoice
+// @Description Generate an invoice from billable, not yet invoiced task logs of the period (both dates inclusive), optionally limited to a client or a user.
+// @Description Days start at midnight in time_zone, or in the user's time zone when user_id is set, or in UTC.
+// @Description Only logs priced in the invoice currency are included; lines aggregate time per task and rate. Included logs are locked until the invoice is voided.
+// @Tags invoices
+// @Accept json
+// @Produce json
+// @Param invoice body models.InvoiceRequest true "Invoice parameters"
+// @Success 201 {object} models.Invoice
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /invoices [post]
+func CreateInvoiceHandler(c *gin.Context, validate *validator.Validate) {
+	var request models.InvoiceRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&request); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	from, _ := time.Parse("2006-01-02", request.From)
+	to, _ := time.Parse("2006-01-02", request.To)
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "to must not be before from"})
+		return
+	}
+
+	if request.ClientID != nil && !recordExists(c, &models.Client{}, *request.ClientID, "Client not found") {
+		return
+	}
+
+	// Границы периода в поясе из запроса, иначе в поясе пользователя счёта, иначе в UTC
+	loc := time.UTC
+	if request.UserID != nil {
+		var user models.User
+		if err := config.DB.First(&user, *request.UserID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "User not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			}
+			return
+		}
+		loc = userLocation(user)
+	}
+	if request.TimeZone != "" {
+		loc, _ = time.LoadLocation(request.TimeZone)
+	}
+
+	periodStart := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	periodEnd := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+
+	db := config.DB.Where("invoice_id IS NULL AND billable = ?", true).
+		Where("start_time >= ? AND end_time < ? AND end_time > start_time", periodStart.UTC(), periodEnd.UTC())
+	if request.UserID != nil {
+		db = db.Where("user_id = ?", *request.UserID)
+	}
+	if request.ClientID != nil {
+		projects := config.DB.Model(&models.Project{}).Select("id").Where("client_id = ?", *request.ClientID)
+		db = db.Where("task_id IN (?)", config.DB.Model(&models.Task{}).Select("id").Where("project_id IN (?)", projects))
+	}
+
+	var taskLogs []models.TaskLog
+	if err := db.Order("start_time, id").Find(&taskLogs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	invoice, logIDs, err := buildInvoice(taskLogs, request.Currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if len(logIDs) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Nothing to invoice for the period"})
+		return
+	}
+
+	invoice.ClientID = request.ClientID
+	invoice.UserID = request.UserID
+	invoice.PeriodFrom = from
+	invoice.PeriodTo = to
+	invoice.Status = models.InvoiceIssued
+	invoice.Version = 1
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		number, err := nextInvoiceNumber(tx)
+		if err != nil {
+			return err
+		}
+		invoice.Number = number
+
+		if err := tx.Create(&invoice).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.TaskLog{}).Where("id IN ? AND invoice_id IS NULL", logIDs).
+			Updates(map[string]interface{}{"invoice_id": invoice.ID, "version": gorm.Expr("version + 1")})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(logIDs)) {
+			return errLogsInvoiced
+		}
+
+		return nil
+	})
+	if err == errLogsInvoiced {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Some task logs have been invoiced concurrently"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, invoice.Version)
+	c.JSON(http.StatusCreated, invoice)
+}
+
+// Аннулирование счёта
+// @Summary Void an invoice
+// @Description Mark an invoice as void and unlock its task logs so they can be edited and invoiced again. The invoice number is not reused.
+// @Tags invoices
+// @Accept json
+// @Produce json
+// @Param id path int true "Invoice ID"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.Invoice
+// @Header 200 {string} ETag "Resource version"
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /invoices/{id}/void [post]
+func VoidInvoiceHandler(c *gin.Context) {
+	id := c.Param("id")
+	var invoice models.Invoice
+
+	result := config.DB.Preload("Lines", orderByID).First(&invoice, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Invoice not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, invoice.Version) {
+		return
+	}
+
+	if invoice.Status != models.InvoiceIssued {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Invoice is already void"})
+		return
+	}
+
+	version := invoice.Version
+	invoice.Status = models.InvoiceVoid
+	invoice.Version = version + 1
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&invoice).Where("version = ?", version).
+			Updates(map[string]interface{}{"status": invoice.Status, "version": invoice.Version})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVersionConflict
+		}
+
+		return tx.Model(&models.TaskLog{}).Where("invoice_id = ?", invoice.ID).
+			Updates(map[string]interface{}{"invoice_id": nil, "version": gorm.Expr("version + 1")}).Error
+	})
+	if err == errVersionConflict {
+		versionConflict(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, invoice.Version)
+	c.JSON(http.StatusOK, invoice)
+}
+
+// Экспорт счёта в PDF
+// @Summary Export an invoice as PDF
+// @Description Download the invoice as a PDF document
+// @Tags invoices
+// @Produce application/pdf
+// @Param id path int true "Invoice ID"
+// @Success 200 {file} file
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /invoices/{id}/pdf [get]
+func GetInvoicePDFHandler(c *gin.Context) {
+	id := c.Param("id")
+	var invoice models.Invoice
+
+	result := config.DB.Preload("Lines", orderByID).First(&invoice, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Invoice not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	var client models.Client
+	if invoice.ClientID != nil {
+		if err := config.DB.First(&client, *invoice.ClientID).Error; err != nil && err != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	var user models.User
+	if invoice.UserID != nil {
+		if err := config.DB.First(&user, *invoice.UserID).Error; err != nil && err != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pdf", invoice.Number))
+	c.Data(http.StatusOK, "application/pdf", invoicePDF(invoice, client, user))
+}
+
+// Строки счёта из логов: округлённое время и сумма по задаче и ставке.
+// Возвращает id логов, вошедших в счёт.
+func buildInvoice(taskLogs []models.TaskLog, currency string) (models.Invoice, []uint, error) {
+	invoice := models.Invoice{Currency: currency, Lines: []models.InvoiceLine{}}
+
+	taskIDs := make(map[uint]int)
+	for _, log := range taskLogs {
+		taskIDs[log.TaskID] = 0
+	}
+
+	tasks := make(map[uint]models.Task)
+	if len(taskIDs) > 0 {
+		var found []models.Task
+		if err := config.DB.Find(&found, mapKeys(taskIDs)).Error; err != nil {
+			return invoice, nil, err
+		}
+		for _, task := range found {
+			tasks[task.ID] = task
+		}
+	}
+
+	rates, err := rateTaskLogs(taskLogs, tasks)
+	if err != nil {
+		return invoice, nil, err
+	}
+
+	logMinutes, err := roundTaskLogs(taskLogs, tasks)
+	if err != nil {
+		return invoice, nil, err
+	}
+
+	type lineKey struct {
+		taskID uint
+		rate   money.Amount
+	}
+	lines := make(map[lineKey]*models.InvoiceLine)
+	var logIDs []uint
+
+	for _, log := range taskLogs {
+		rate, ok := rates[log.ID]
+		if !ok || rate.Currency != currency {
+			continue
+		}
+
+		key := lineKey{log.TaskID, rate.Amount}
+		line, ok := lines[key]
+		if !ok {
+			line = &models.InvoiceLine{TaskID: log.TaskID, Description: tasks[log.TaskID].Title, Rate: rate.Amount}
+			lines[key] = line
+		}
+
+		minutes := logMinutes[log.ID]
+		amount, err := rate.Amount.ForMinutes(minutes)
+		if err != nil {
+			return invoice, nil, err
+		}
+		if line.Amount, err = line.Amount.Add(amount); err != nil {
+			return invoice, nil, err
+		}
+		if invoice.Total, err = invoice.Total.Add(amount); err != nil {
+			return invoice, nil, err
+		}
+		line.Minutes += minutes
+		logIDs = append(logIDs, log.ID)
+	}
+
+	for _, line := range lines {
+		invoice.Lines = append(invoice.Lines, *line)
+	}
+	sort.Slice(invoice.Lines, func(i, j int) bool {
+		if invoice.Lines[i].TaskID == invoice.Lines[j].TaskID {
+			return invoice.Lines[i].Rate < invoice.Lines[j].Rate
+		}
+		return invoice.Lines[i].TaskID < invoice.Lines[j].TaskID
+	})
+
+	return invoice, logIDs, nil
+}
+
+// Следующий номер счёта. Строка счётчика остаётся заблокированной до конца транзакции,
+// поэтому номера идут подряд и без пропусков при откате.
+func nextInvoiceNumber(tx *gorm.DB) (string, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.InvoiceSequence{ID: 1}).Error; err != nil {
+		return "", err
+	}
+
+	if err := tx.Model(&models.InvoiceSequence{}).Where("id = ?", 1).Update("value", gorm.Expr("value + 1")).Error; err != nil {
+		return "", err
+	}
+
+	var sequence models.InvoiceSequence
+	if err := tx.First(&sequence, 1).Error; err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("INV-%06d", sequence.Value), nil
+}
+
+func invoicePDF(invoice models.Invoice, client models.Client, user models.User) []byte {
+	doc := pdf.New()
+
+	doc.Text(pdf.Margin, 20, true, "Invoice "+invoice.Number)
+	if invoice.Status == models.InvoiceVoid {
+		doc.Text(400, 20, true, "VOID")
+	}
+	doc.Newline(20)
+	doc.Newline(10)
+
+	doc.Text(pdf.Margin, 10, false, "Date: "+invoice.CreatedAt.Format("2006-01-02"))
+	doc.Newline(10)
+	doc.Text(pdf.Margin, 10, false, "Period: "+invoice.PeriodFrom.Format("2006-01-02")+" - "+invoice.PeriodTo.Format("2006-01-02"))
+	doc.Newline(10)
+	if invoice.ClientID != nil {
+		doc.Text(pdf.Margin, 10, false, "Client: "+client.Name)
+		doc.Newline(10)
+	}
+	if invoice.UserID != nil {
+		doc.Text(pdf.Margin, 10, false, "User: "+user.Name+" "+user.Surname)
+		doc.Newline(10)
+	}
+	doc.Newline(10)
+
+	columns := []float64{pdf.Margin, 330, 400, 480}
+	for i, title := range []string{"Task", "Time", "Rate/h", "Amount"} {
+		doc.Text(columns[i], 10, true, title)
+	}
+	doc.Newline(10)
+
+	for _, line := range invoice.Lines {
+		doc.Text(columns[0], 10, false, fmt.Sprintf("#%d %s", line.TaskID, line.Description))
+		doc.Text(columns[1], 10, false, fmt.Sprintf("%d:%02d", line.Minutes/60, line.Minutes%60))
+		doc.Text(columns[2], 10, false, line.Rate.String())
+		doc.Text(columns[3], 10, false, line.Amount.String())
+		doc.Newline(10)
+	}
+
+	doc.Newline(10)
+	doc.Text(columns[2], 12, true, "Total")
+	doc.Text(columns[3], 12, true, invoice.Total.String()+" "+invoice.Currency)
+	doc.Newline(12)
+
+	return doc.Bytes()
+}
+
+// Ответ 409, если лог вошёл в выставленный счёт и не может быть изменён
+func invoicedTaskLog(c *gin.Context, taskLog models.TaskLog) bool {
+	if taskLog.InvoiceID == nil {
+		return false
+	}
+
+	c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Task log is invoiced and cannot be changed"})
+	return true
+}
+
+func orderByID(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
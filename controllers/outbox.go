@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"context"
+	"em-test/config"
+	"em-test/models"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Сколько событий relay передаёт брокеру за один запуск
+const outboxBatchSize = 100
+
+// Сколько хранятся переданные события; по ним клиенты /events возобновляют поток
+const outboxRetention = 7 * 24 * time.Hour
+
+// Время ожидания подтверждения от брокера
+const outboxPublishTimeout = 5 * time.Second
+
+// На это время передача событий закрепляется за одной репликой
+const outboxLease = time.Minute
+
+// Запись события в outbox в транзакции изменения: событие появится,
+// только если изменение сохранено
+func recordEvent(tx *gorm.DB, event models.Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	row := models.OutboxEvent{Type: event.Type, UserID: event.UserID, TaskID: event.TaskID, Data: string(data)}
+	return tx.Create(&row).Error
+}
+
+func recordTimerEvent(tx *gorm.DB, eventType string, taskLog models.TaskLog) error {
+	userID := taskLog.UserID
+	return recordEvent(tx, models.Event{Type: eventType, UserID: &userID, TaskID: taskLog.TaskID, Data: taskLog})
+}
+
+func recordTaskEvent(tx *gorm.DB, eventType string, task models.Task) error {
+	return recordEvent(tx, models.Event{Type: eventType, TaskID: task.ID, Data: task})
+}
+
+func recordUserEvent(tx *gorm.DB, eventType string, user models.User) error {
+	userID := user.ID
+	return recordEvent(tx, models.Event{Type: eventType, UserID: &userID, Data: user})
+}
+
+// Передача новых событий из outbox брокеру и постановка их в очередь вебхуков.
+// Вызывается планировщиком. События передаёт одна реплика, взявшая аренду relay,
+// по порядку Sequence; брокер вызывается вне транзакций. Доставка «хотя бы один раз»:
+// если отметка не сохранится, событие будет передано повторно с тем же id.
+func RelayOutbox(now time.Time) (err error) {
+	leasedUntil := now.Add(outboxLease)
+	leased, err := acquireOutboxLease(now, leasedUntil)
+	if err != nil || !leased {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, releaseOutboxLease(leasedUntil))
+	}()
+
+	rows, err := sequenceOutboxEvents()
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		// Аренда не должна истечь посреди передачи; остальное передаст следующий запуск
+		if time.Now().Add(outboxPublishTimeout).After(leasedUntil) {
+			break
+		}
+
+		event := outboxEvent(row)
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		// Следующие события ждут, пока это не будет передано, чтобы не нарушить порядок
+		ctx, cancel := context.WithTimeout(context.Background(), outboxPublishTimeout)
+		err = config.Broker.Publish(ctx, config.BrokerSubject, payload)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		err = config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := enqueueWebhookDeliveries(tx, event); err != nil {
+				return err
+			}
+			return tx.Model(&row).Update("published_at", now).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return config.DB.Where("published_at < ?", now.Add(-outboxRetention)).Delete(&models.OutboxEvent{}).Error
+}
+
+// Аренда relay закрепляется условным обновлением строки состояния, поэтому
+// при запуске на нескольких репликах события передаёт только одна из них
+func acquireOutboxLease(now, leasedUntil time.Time) (bool, error) {
+	if err := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.OutboxRelay{ID: 1}).Error; err != nil {
+		return false, err
+	}
+
+	result := config.DB.Model(&models.OutboxRelay{}).
+		Where("id = ? AND leased_until <= ?", 1, now).
+		Update("leased_until", leasedUntil)
+	return result.RowsAffected > 0, result.Error
+}
+
+func releaseOutboxLease(leasedUntil time.Time) error {
+	return config.DB.Model(&models.OutboxRelay{}).
+		Where("id = ? AND leased_until = ?", 1, leasedUntil).
+		Update("leased_until", time.Time{}).Error
+}
+
+// Следующие события для передачи: сначала получившие Sequence, но не переданные
+// в прошлый раз, затем новые, которым Sequence назначается по порядку id.
+// Вызывается только под арендой relay.
+func sequenceOutboxEvents() ([]models.OutboxEvent, error) {
+	var rows []models.OutboxEvent
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("published_at IS NULL").Order("sequence IS NULL, sequence, id").
+			Limit(outboxBatchSize).Find(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		var count uint64
+		for _, row := range rows {
+			if row.Sequence == nil {
+				count++
+			}
+		}
+		if count == 0 {
+			return nil
+		}
+
+		err = tx.Model(&models.OutboxRelay{}).Where("id = ?", 1).Update("sequence", gorm.Expr("sequence + ?", count)).Error
+		if err != nil {
+			return err
+		}
+		var relay models.OutboxRelay
+		if err := tx.First(&relay, 1).Error; err != nil {
+			return err
+		}
+
+		next := relay.Sequence - count
+		for i := range rows {
+			if rows[i].Sequence != nil {
+				continue
+			}
+			next++
+			sequence := next
+			rows[i].Sequence = &sequence
+			if err := tx.Model(&rows[i]).Update("sequence", sequence).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return rows, err
+}
+
+// Подписка этого процесса на события брокера для потоков /events
+func StartEventStream() error {
+	_, err := config.Broker.Subscribe(config.BrokerSubject, func(data []byte) {
+		var message outboxMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			return
+		}
+		config.Events.Publish(message.event())
+	})
+	return err
+}
+
+func outboxEvent(row models.OutboxEvent) models.Event {
+	return models.Event{
+		ID:     row.ID,
+		Type:   row.Type,
+		UserID: row.UserID,
+		TaskID: row.TaskID,
+		Time:   row.CreatedAt.UTC(),
+		Data:   json.RawMessage(row.Data),
+	}
+}
+
+// Событие в сообщении брокера; Data остаётся в исходном JSON
+type outboxMessage struct {
+	ID     uint64          `json:"id"`
+	Type   string          `json:"type"`
+	UserID *uint           `json:"user_id"`
+	TaskID uint            `json:"task_id"`
+	Time   time.Time       `json:"time"`
+	Data   json.RawMessage `json:"data"`
+}
+
+func (m outboxMessage) event() models.Event {
+	return models.Event{ID: m.ID, Type: m.Type, UserID: m.UserID, TaskID: m.TaskID, Time: m.Time, Data: m.Data}
+}
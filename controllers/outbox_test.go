@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	"em-test/config"
+	"em-test/events"
+	"em-test/models"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Брокер, запоминающий id переданных событий; после limit сообщений отвечает ошибкой
+type recordingBroker struct {
+	ids   []uint64
+	limit int
+}
+
+func (b *recordingBroker) Publish(ctx context.Context, subject string, data []byte) error {
+	if b.limit >= 0 && len(b.ids) >= b.limit {
+		return errors.New("broker unavailable")
+	}
+	var message outboxMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		return err
+	}
+	b.ids = append(b.ids, message.ID)
+	return nil
+}
+
+func (b *recordingBroker) Subscribe(subject string, handler func(data []byte)) (func(), error) {
+	return func() {}, nil
+}
+
+func (b *recordingBroker) Close() error {
+	return nil
+}
+
+func setupOutbox(t *testing.T, limit int) *recordingBroker {
+	t.Helper()
+
+	setupTestDB(t, &models.OutboxEvent{}, &models.OutboxRelay{}, &models.Webhook{}, &models.WebhookDelivery{})
+
+	b := &recordingBroker{limit: limit}
+	previous := config.Broker
+	config.Broker = b
+	t.Cleanup(func() { config.Broker = previous })
+	return b
+}
+
+func insertOutboxEvent(t *testing.T, id uint64, eventType string) {
+	t.Helper()
+
+	row := models.OutboxEvent{ID: id, Type: eventType, Data: "{}"}
+	if err := config.DB.Create(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func outboxRows(t *testing.T) []models.OutboxEvent {
+	t.Helper()
+
+	var rows []models.OutboxEvent
+	if err := config.DB.Order("id").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestRelayOutboxPublishesRows(t *testing.T) {
+	b := setupOutbox(t, -1)
+	active := true
+	hook := models.Webhook{URL: "https://example.com/hook", EventTypes: []string{models.EventUserCreated}, Secret: "secret", Active: &active}
+	if err := config.DB.Create(&hook).Error; err != nil {
+		t.Fatal(err)
+	}
+	insertOutboxEvent(t, 1, models.EventUserCreated)
+	insertOutboxEvent(t, 2, models.EventTaskCreated)
+	insertOutboxEvent(t, 3, models.EventUserCreated)
+
+	now := time.Now().UTC()
+	if err := RelayOutbox(now); err != nil {
+		t.Fatalf("RelayOutbox: %v", err)
+	}
+
+	if want := []uint64{1, 2, 3}; !slices.Equal(b.ids, want) {
+		t.Errorf("published %v, want %v", b.ids, want)
+	}
+	for i, row := range outboxRows(t) {
+		if row.PublishedAt == nil {
+			t.Errorf("event %d is not marked published", row.ID)
+		}
+		if row.Sequence == nil || *row.Sequence != uint64(i+1) {
+			t.Errorf("event %d has sequence %v, want %d", row.ID, row.Sequence, i+1)
+		}
+	}
+
+	var deliveries int64
+	config.DB.Model(&models.WebhookDelivery{}).Count(&deliveries)
+	if deliveries != 2 {
+		t.Errorf("enqueued %d webhook deliveries, want 2", deliveries)
+	}
+
+	var relay models.OutboxRelay
+	config.DB.First(&relay, 1)
+	if relay.LeasedUntil.After(now) {
+		t.Errorf("lease was not released: leased until %v", relay.LeasedUntil)
+	}
+
+	// Повторный запуск ничего не передаёт
+	if err := RelayOutbox(now.Add(time.Second)); err != nil {
+		t.Fatalf("RelayOutbox: %v", err)
+	}
+	if len(b.ids) != 3 {
+		t.Errorf("published %v after the second run, want 3 events", b.ids)
+	}
+}
+
+// После ошибки брокера непереданные события передаются следующим запуском в прежнем порядке
+func TestRelayOutboxRetriesInOrder(t *testing.T) {
+	b := setupOutbox(t, 1)
+	insertOutboxEvent(t, 10, models.EventTaskCreated)
+	insertOutboxEvent(t, 11, models.EventTaskCreated)
+
+	now := time.Now().UTC()
+	if err := RelayOutbox(now); err == nil {
+		t.Fatal("RelayOutbox did not return the broker error")
+	}
+	rows := outboxRows(t)
+	if rows[0].PublishedAt == nil || rows[1].PublishedAt != nil || rows[1].Sequence == nil {
+		t.Fatalf("published flags after the failure: %v, %v", rows[0].PublishedAt, rows[1].PublishedAt)
+	}
+
+	// Событие с меньшим id, зафиксированное позже, получает следующий номер
+	insertOutboxEvent(t, 5, models.EventTaskUpdated)
+	b.limit = -1
+	if err := RelayOutbox(now.Add(time.Second)); err != nil {
+		t.Fatalf("RelayOutbox: %v", err)
+	}
+
+	if want := []uint64{10, 11, 5}; !slices.Equal(b.ids, want) {
+		t.Errorf("published %v, want %v", b.ids, want)
+	}
+}
+
+// Пока аренда relay у другой реплики, события не передаются
+func TestRelayOutboxRespectsLease(t *testing.T) {
+	b := setupOutbox(t, -1)
+	insertOutboxEvent(t, 1, models.EventTaskCreated)
+
+	now := time.Now().UTC()
+	if err := config.DB.Create(&models.OutboxRelay{ID: 1, LeasedUntil: now.Add(time.Minute)}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RelayOutbox(now); err != nil {
+		t.Fatalf("RelayOutbox: %v", err)
+	}
+	if len(b.ids) != 0 {
+		t.Errorf("published %v while another replica holds the lease", b.ids)
+	}
+
+	if err := RelayOutbox(now.Add(2 * time.Minute)); err != nil {
+		t.Fatalf("RelayOutbox: %v", err)
+	}
+	if want := []uint64{1}; !slices.Equal(b.ids, want) {
+		t.Errorf("published %v after the lease expired, want %v", b.ids, want)
+	}
+}
+
+// Поток возобновляется по порядку передачи, а не по id
+func TestEventBacklogResumesBySequence(t *testing.T) {
+	setupOutbox(t, -1)
+	insertOutboxEvent(t, 10, models.EventTaskCreated)
+	insertOutboxEvent(t, 11, models.EventTaskCreated)
+	now := time.Now().UTC()
+	if err := RelayOutbox(now); err != nil {
+		t.Fatal(err)
+	}
+	insertOutboxEvent(t, 5, models.EventTaskUpdated)
+	if err := RelayOutbox(now.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		lastID uint64
+		want   []uint64
+		resync bool
+	}{
+		{lastID: 10, want: []uint64{11, 5}},
+		{lastID: 11, want: []uint64{5}},
+		{lastID: 5, want: []uint64{}},
+		{lastID: 4, resync: true},
+	}
+
+	for _, tt := range tests {
+		backlog, err := eventBacklog(events.Filter{}, tt.lastID)
+		if err != nil {
+			t.Fatalf("eventBacklog(%d): %v", tt.lastID, err)
+		}
+		if tt.resync {
+			if len(backlog) != 1 || backlog[0].Type != models.EventResync {
+				t.Errorf("eventBacklog(%d) = %v, want resync", tt.lastID, backlog)
+			}
+			continue
+		}
+		ids := make([]uint64, len(backlog))
+		for i, event := range backlog {
+			ids[i] = event.ID
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("eventBacklog(%d) = %v, want %v", tt.lastID, ids, tt.want)
+		}
+	}
+}
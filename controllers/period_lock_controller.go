@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"em-test/query"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// Поля блокировки периода, доступные для фильтрации и сортировки
+var periodLockListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":           {Column: "id", Type: query.Int},
+		"user_id":      {Column: "user_id", Type: query.Int},
+		"locked_until": {Column: "locked_until", Type: query.Time},
+		"created_at":   {Column: "created_at", Type: query.Time},
+	},
+	DefaultSort: "-locked_until",
+}
+
+// Получение списка закрытых периодов
+// @Summary Get all period locks
+// @Description Get a paginated list of period locks. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
+// @Tags period-locks
+// @Accept json
+// @Produce json
+// @Param user_id query int false "User ID"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -locked_until)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.PeriodLock]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /period-locks [get]
+func GetPeriodLocksHandler(c *gin.Context) {
+	params, err := query.Parse(c.Request.URL.Query(), periodLockListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var locks []models.PeriodLock
+	total, err := params.Find(config.DB.Model(&models.PeriodLock{}), &locks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(locks, total, params, c.Request.URL))
+}
+
+// Получение закрытого периода по id
+// @Summary Get period lock by ID
+// @Description Get a single period lock by its ID
+// @Tags period-locks
+// @Accept json
+// @Produce json
+// @Param id path int true "Period Lock ID"
+// @Param If-None-Match header string false "ETag of a cached version"
+// @Success 200 {object} models.PeriodLock
+// @Header 200 {string} ETag "Resource version"
+// @Success 304
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /period-locks/{id} [get]
+func GetPeriodLockHandler(c *gin.Context) {
+	id := c.Param("id")
+	var lock models.PeriodLock
+
+	result := config.DB.First(&lock, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Period lock not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if notModified(c, lock.Version) {
+		return
+	}
+
+	setETag(c, lock.Version)
+	c.JSON(http.StatusOK, lock)
+}
+
+// Закрытие периода
+// @Summary Create a period lock
+// @Description Lock all task logs started before locked_until, for one user or, without user_id, for everyone.
+// @Description Locked logs cannot be created, completed or changed; such requests fail with 423 Locked.
+// @Tags period-locks
+// @Accept json
+// @Produce json
+// @Param lock body models.PeriodLock true "Period Lock JSON"
+// @Success 201 {object} models.PeriodLock
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /period-locks [post]
+func CreatePeriodLockHandler(c *gin.Context, validate *validator.Validate) {
+	var lock models.PeriodLock
+
+	if err := c.ShouldBindJSON(&lock); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&lock); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if lock.UserID != nil && !recordExists(c, &models.User{}, *lock.UserID, "User not found") {
+		return
+	}
+
+	lock.Version = 1
+	result := config.DB.Create(&lock)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+
+	setETag(c, lock.Version)
+	c.JSON(http.StatusCreated, lock)
+}
+
+// Изменение закрытого периода
+// @Summary Update a period lock
+// @Description Move the lock date or change the scope of a period lock by ID
+// @Tags period-locks
+// @Accept json
+// @Produce json
+// @Param id path int true "Period Lock ID"
+// @Param lock body models.PeriodLock true "Period Lock data"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.PeriodLock
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /period-locks/{id} [put]
+func UpdatePeriodLockHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var lock models.PeriodLock
+
+	result := config.DB.First(&lock, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Period lock not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, lock.Version) {
+		return
+	}
+
+	lockID, version, createdAt := lock.ID, lock.Version, lock.CreatedAt
+
+	if err := c.ShouldBindJSON(&lock); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&lock); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if lock.UserID != nil && !recordExists(c, &models.User{}, *lock.UserID, "User not found") {
+		return
+	}
+
+	lock.ID = lockID
+	lock.CreatedAt = createdAt
+	lock.Version = version + 1
+
+	result = config.DB.Select("*").Where("version = ?", version).Save(&lock)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	setETag(c, lock.Version)
+	c.JSON(http.StatusOK, lock)
+}
+
+// Открытие закрытого периода
+// @Summary Delete a period lock
+// @Description Delete a period lock by ID. Logs stay locked while another lock covers them.
+// @Tags period-locks
+// @Accept json
+// @Produce json
+// @Param id path int true "Period Lock ID"
+// @Param If-Match header string false "Expected ETag"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /period-locks/{id} [delete]
+func DeletePeriodLockHandler(c *gin.Context) {
+	id := c.Param("id")
+	var lock models.PeriodLock
+
+	result := config.DB.First(&lock, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Period lock not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, lock.Version) {
+		return
+	}
+
+	result = config.DB.Where("version = ?", lock.Version).Delete(&lock)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Ответ 423, если момент at попадает в закрытый период пользователя
+func periodLocked(c *gin.Context, userID uint, at time.Time) bool {
+	lock, locked, err := periodLockAt(userID, at)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return true
+	}
+	if !locked {
+		return false
+	}
+
+	c.JSON(http.StatusLocked, models.ErrorResponse{Error: "Period is locked until " + lock.LockedUntil.Format(time.RFC3339)})
+	return true
+}
+
+// Самая поздняя блокировка, закрывающая момент at для пользователя
+func periodLockAt(userID uint, at time.Time) (models.PeriodLock, bool, error) {
+	var lock models.PeriodLock
+
+	result := config.DB.Where("(user_id IS NULL OR user_id = ?) AND locked_until > ?", userID, at).
+		Order("locked_until DESC").Limit(1).Find(&lock)
+	return lock, result.RowsAffected > 0, result.Error
+}
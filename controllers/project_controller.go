@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"em-test/query"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// Поля проекта, доступные для фильтрации и сортировки
+var projectListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Type: query.Int},
+		"client_id":  {Column: "client_id", Type: query.Int},
+		"name":       {Column: "name", Type: query.String, DefaultOp: query.Contains},
+		"created_at": {Column: "created_at", Type: query.Time},
+		"updated_at": {Column: "updated_at", Type: query.Time},
+	},
+}
+
+// Получение списка проектов
+// @Summary Get all projects
+// @Description Get a paginated list of projects. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param client_id query int false "Client ID"
+// @Param name query string false "Name contains"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. name)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.Project]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /projects [get]
+func GetProjectsHandler(c *gin.Context) {
+	params, err := query.Parse(c.Request.URL.Query(), projectListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var projects []models.Project
+	total, err := params.Find(config.DB.Model(&models.Project{}), &projects)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(projects, total, params, c.Request.URL))
+}
+
+// Получение проекта по id
+// @Summary Get project by ID
+// @Description Get a single project by its ID
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param If-None-Match header string false "ETag of a cached version"
+// @Success 200 {object} models.Project
+// @Header 200 {string} ETag "Resource version"
+// @Success 304
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /projects/{id} [get]
+func GetProjectHandler(c *gin.Context) {
+	id := c.Param("id")
+	var project models.Project
+
+	result := config.DB.First(&project, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Project not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if notModified(c, project.Version) {
+		return
+	}
+
+	setETag(c, project.Version)
+	c.JSON(http.StatusOK, project)
+}
+
+// Создание нового проекта
+// @Summary Create a new project
+// @Description Create a new project with the input payload
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param project body models.Project true "Project JSON"
+// @Success 201 {object} models.Project
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /projects [post]
+func CreateProjectHandler(c *gin.Context, validate *validator.Validate) {
+	var project models.Project
+
+	if err := c.ShouldBindJSON(&project); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&project); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if !recordExists(c, &models.Client{}, project.ClientID, "Client not found") {
+		return
+	}
+
+	project.Version = 1
+	result := config.DB.Create(&project)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+
+	setETag(c, project.Version)
+	c.JSON(http.StatusCreated, project)
+}
+
+// Изменение данных проекта
+// @Summary Update a project
+// @Description Update project details by ID
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param project body models.Project true "Project data"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.Project
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /projects/{id} [put]
+func UpdateProjectHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var project models.Project
+
+	result := config.DB.First(&project, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Project not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, project.Version) {
+		return
+	}
+
+	projectID, version, createdAt := project.ID, project.Version, project.CreatedAt
+
+	if err := c.ShouldBindJSON(&project); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&project); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if !recordExists(c, &models.Client{}, project.ClientID, "Client not found") {
+		return
+	}
+
+	project.ID = projectID
+	project.CreatedAt = createdAt
+	project.Version = version + 1
+
+	result = config.DB.Select("*").Where("version = ?", version).Save(&project)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	setETag(c, project.Version)
+	c.JSON(http.StatusOK, project)
+}
+
+// Удаление проекта
+// @Summary Delete a project
+// @Description Delete a project by ID. Projects that still have tasks, task templates, rates or rounding policies cannot be deleted.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param If-Match header string false "Expected ETag"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /projects/{id} [delete]
+func DeleteProjectHandler(c *gin.Context) {
+	id := c.Param("id")
+	var project models.Project
+
+	result := config.DB.First(&project, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Project not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, project.Version) {
+		return
+	}
+
+	var tasks int64
+	if err := config.DB.Model(&models.Task{}).Where("project_id = ?", project.ID).Count(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if tasks > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Project has tasks"})
+		return
+	}
+
+	var templates int64
+	if err := config.DB.Model(&models.TaskTemplate{}).Where("project_id = ?", project.ID).Count(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if templates > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Project has task templates"})
+		return
+	}
+
+	var rates int64
+	if err := config.DB.Model(&models.Rate{}).Where("project_id = ?", project.ID).Count(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if rates > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Project has rates"})
+		return
+	}
+
+	var policies int64
+	if err := config.DB.Model(&models.RoundingPolicy{}).Where("project_id = ?", project.ID).Count(&policies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if policies > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Project has rounding policies"})
+		return
+	}
+
+	result = config.DB.Where("version = ?", project.Version).Delete(&project)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
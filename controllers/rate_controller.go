@@ -0,0 +1,509 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"em-test/money"
+	"em-test/query"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// Поля ставки, доступные для фильтрации и сортировки
+var rateListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":             {Column: "id", Type: query.Int},
+		"user_id":        {Column: "user_id", Type: query.Int},
+		"project_id":     {Column: "project_id", Type: query.Int},
+		"task_id":        {Column: "task_id", Type: query.Int},
+		"currency":       {Column: "currency", Type: query.String},
+		"effective_from": {Column: "effective_from", Type: query.Time},
+		"created_at":     {Column: "created_at", Type: query.Time},
+	},
+	DefaultSort: "-effective_from",
+}
+
+// Суммы по валютам
+type costs map[string]money.Amount
+
+// Получение списка ставок
+// @Summary Get all rates
+// @Description Get a paginated list of hourly rates. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
+// @Tags rates
+// @Accept json
+// @Produce json
+// @Param user_id query int false "User ID"
+// @Param project_id query int false "Project ID"
+// @Param task_id query int false "Task ID"
+// @Param currency query string false "Currency"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -effective_from)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.Rate]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /rates [get]
+func GetRatesHandler(c *gin.Context) {
+	params, err := query.Parse(c.Request.URL.Query(), rateListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var rates []models.Rate
+	total, err := params.Find(config.DB.Model(&models.Rate{}), &rates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(rates, total, params, c.Request.URL))
+}
+
+// Получение ставки по id
+// @Summary Get rate by ID
+// @Description Get a single hourly rate by its ID
+// @Tags rates
+// @Accept json
+// @Produce json
+// @Param id path int true "Rate ID"
+// @Param If-None-Match header string false "ETag of a cached version"
+// @Success 200 {object} models.Rate
+// @Header 200 {string} ETag "Resource version"
+// @Success 304
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /rates/{id} [get]
+func GetRateHandler(c *gin.Context) {
+	id := c.Param("id")
+	var rate models.Rate
+
+	result := config.DB.First(&rate, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Rate not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if notModified(c, rate.Version) {
+		return
+	}
+
+	setETag(c, rate.Version)
+	c.JSON(http.StatusOK, rate)
+}
+
+// Создание ставки
+// @Summary Create a rate
+// @Description Create an hourly rate for a user, a task or a project, or a default rate when none is set.
+// @Description For a log the most specific rate wins: user and task, task, user and project, project, user, default. Among rates of the same scope the latest one in effect when the log started is used.
+// @Tags rates
+// @Accept json
+// @Produce json
+// @Param rate body models.Rate true "Rate JSON"
+// @Success 201 {object} models.Rate
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /rates [post]
+func CreateRateHandler(c *gin.Context, validate *validator.Validate) {
+	var rate models.Rate
+
+	if err := c.ShouldBindJSON(&rate); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&rate); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if !rateScopeExists(c, rate) {
+		return
+	}
+
+	rate.Version = 1
+	result := config.DB.Create(&rate)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+
+	setETag(c, rate.Version)
+	c.JSON(http.StatusCreated, rate)
+}
+
+// Изменение ставки
+// @Summary Update a rate
+// @Description Update an hourly rate by ID. Reports are always calculated with the current rates.
+// @Tags rates
+// @Accept json
+// @Produce json
+// @Param id path int true "Rate ID"
+// @Param rate body models.Rate true "Rate data"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.Rate
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /rates/{id} [put]
+func UpdateRateHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var rate models.Rate
+
+	result := config.DB.First(&rate, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Rate not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, rate.Version) {
+		return
+	}
+
+	rateID, version, createdAt := rate.ID, rate.Version, rate.CreatedAt
+
+	if err := c.ShouldBindJSON(&rate); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&rate); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if !rateScopeExists(c, rate) {
+		return
+	}
+
+	rate.ID = rateID
+	rate.CreatedAt = createdAt
+	rate.Version = version + 1
+
+	result = config.DB.Select("*").Where("version = ?", version).Save(&rate)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	setETag(c, rate.Version)
+	c.JSON(http.StatusOK, rate)
+}
+
+// Удаление ставки
+// @Summary Delete a rate
+// @Description Delete an hourly rate by ID
+// @Tags rates
+// @Accept json
+// @Produce json
+// @Param id path int true "Rate ID"
+// @Param If-Match header string false "Expected ETag"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /rates/{id} [delete]
+func DeleteRateHandler(c *gin.Context) {
+	id := c.Param("id")
+	var rate models.Rate
+
+	result := config.DB.First(&rate, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Rate not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, rate.Version) {
+		return
+	}
+
+	result = config.DB.Where("version = ?", rate.Version).Delete(&rate)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Отметка TaskLog как оплачиваемого или нет
+// @Summary Set task log billable flag
+// @Description Mark a task log as billable or non-billable. Only billable logs have a cost in reports.
+// @Tags tasklogs
+// @Accept json
+// @Produce json
+// @Param id path int true "Task Log ID"
+// @Param billable body models.BillableRequest true "Billable flag"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.TaskLog
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasklogs/{id}/billable [put]
+func SetTaskLogBillableHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var taskLog models.TaskLog
+
+	result := config.DB.First(&taskLog, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task log not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, taskLog.Version) {
+		return
+	}
+
+	if taskLogLocked(c, taskLog) {
+		return
+	}
+
+	var request models.BillableRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&request); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	version := taskLog.Version
+	taskLog.Billable = request.Billable
+	taskLog.Version = version + 1
+
+	result = config.DB.Select("*").Where("version = ?", version).Save(&taskLog)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	if err := loadTaskLogTags(&taskLog); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, taskLog.Version)
+	c.JSON(http.StatusOK, taskLog)
+}
+
+// Проверка существования пользователя, задачи и проекта, к которым относится ставка
+func rateScopeExists(c *gin.Context, rate models.Rate) bool {
+	if rate.UserID != nil && !recordExists(c, &models.User{}, *rate.UserID, "User not found") {
+		return false
+	}
+	if rate.ProjectID != nil && !recordExists(c, &models.Project{}, *rate.ProjectID, "Project not found") {
+		return false
+	}
+	if rate.TaskID != nil && !recordExists(c, &models.Task{}, *rate.TaskID, "Task not found") {
+		return false
+	}
+	return true
+}
+
+// Стоимость завершённых оплачиваемых логов по id лога за округлённые минуты logMinutes.
+// Логи без подходящей ставки в результат не попадают.
+func priceTaskLogs(taskLogs []models.TaskLog, tasks map[uint]models.Task, logMinutes map[uint]int) (map[uint]models.Cost, error) {
+	rates, err := rateTaskLogs(taskLogs, tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	logCosts := make(map[uint]models.Cost)
+	for _, log := range taskLogs {
+		rate, ok := rates[log.ID]
+		if !ok {
+			continue
+		}
+
+		amount, err := rate.Amount.ForMinutes(logMinutes[log.ID])
+		if err != nil {
+			return nil, err
+		}
+		logCosts[log.ID] = models.Cost{Currency: rate.Currency, Amount: amount}
+	}
+
+	return logCosts, nil
+}
+
+// Ставки завершённых оплачиваемых логов по id лога
+func rateTaskLogs(taskLogs []models.TaskLog, tasks map[uint]models.Task) (map[uint]models.Rate, error) {
+	logRates := make(map[uint]models.Rate)
+
+	var billable []models.TaskLog
+	userIDs, taskIDs, projectIDs := make(map[uint]int), make(map[uint]int), make(map[uint]int)
+	var latestStart time.Time
+	for _, log := range taskLogs {
+		if log.EndTime.IsZero() || (log.Billable != nil && !*log.Billable) {
+			continue
+		}
+
+		billable = append(billable, log)
+		userIDs[log.UserID] = 0
+		taskIDs[log.TaskID] = 0
+		if projectID := tasks[log.TaskID].ProjectID; projectID != nil {
+			projectIDs[*projectID] = 0
+		}
+		if log.StartTime.After(latestStart) {
+			latestStart = log.StartTime
+		}
+	}
+	if len(billable) == 0 {
+		return logRates, nil
+	}
+
+	// Загружаются только ставки, которые могут относиться к этим логам
+	var rates []models.Rate
+	err := config.DB.Where("user_id IS NULL OR user_id IN ?", mapKeys(userIDs)).
+		Where("task_id IS NULL OR task_id IN ?", mapKeys(taskIDs)).
+		Where("project_id IS NULL OR project_id IN ?", mapKeys(projectIDs)).
+		Where("effective_from <= ?", latestStart).
+		Order("effective_from DESC, id DESC").Find(&rates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, log := range billable {
+		if rate := pickRate(rates, log, tasks[log.TaskID]); rate != nil {
+			logRates[log.ID] = *rate
+		}
+	}
+
+	return logRates, nil
+}
+
+// Выбор ставки для лога: самая специфичная из действующих на момент начала лога.
+// rates должны быть отсортированы по effective_from по убыванию.
+func pickRate(rates []models.Rate, log models.TaskLog, task models.Task) *models.Rate {
+	var best *models.Rate
+	bestScore := -1
+
+	for i, rate := range rates {
+		if rate.EffectiveFrom.After(log.StartTime) {
+			continue
+		}
+		if score := rateScore(rate, log, task); score > bestScore {
+			best, bestScore = &rates[i], score
+		}
+	}
+
+	return best
+}
+
+// Специфичность ставки для лога или -1, если ставка к нему не относится.
+// Задача важнее проекта, проект важнее пользователя.
+func rateScore(rate models.Rate, log models.TaskLog, task models.Task) int {
+	score := 0
+
+	if rate.TaskID != nil {
+		if *rate.TaskID != log.TaskID {
+			return -1
+		}
+		score += 4
+	}
+	if rate.ProjectID != nil {
+		if task.ProjectID == nil || *rate.ProjectID != *task.ProjectID {
+			return -1
+		}
+		score += 2
+	}
+	if rate.UserID != nil {
+		if *rate.UserID != log.UserID {
+			return -1
+		}
+		score++
+	}
+
+	return score
+}
+
+func addCost(m map[uint]costs, id uint, cost models.Cost) error {
+	if m[id] == nil {
+		m[id] = make(costs)
+	}
+	sum, err := m[id][cost.Currency].Add(cost.Amount)
+	if err != nil {
+		return err
+	}
+	m[id][cost.Currency] = sum
+	return nil
+}
+
+func mergeCosts(m map[uint]costs, id uint, other costs) error {
+	for currency, amount := range other {
+		if err := addCost(m, id, models.Cost{Currency: currency, Amount: amount}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Суммы по валютам в порядке кодов валют
+func (c costs) list() []models.Cost {
+	list := make([]models.Cost, 0, len(c))
+	for currency, amount := range c {
+		list = append(list, models.Cost{Currency: currency, Amount: amount})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Currency < list[j].Currency
+	})
+
+	return list
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Проверка существования связанной записи; при отсутствии отвечает 400
+func recordExists(c *gin.Context, model interface{}, id uint, message string) bool {
+	var count int64
+
+	if err := config.DB.Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return false
+	}
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: message})
+		return false
+	}
+
+	return true
+}
+
+// Нарушение уникального индекса в PostgreSQL
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
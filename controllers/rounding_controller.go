@@ -0,0 +1,338 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"em-test/query"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Поля правила округления, доступные для фильтрации и сортировки
+var roundingPolicyListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Type: query.Int},
+		"project_id": {Column: "project_id", Type: query.Int},
+		"mode":       {Column: "mode", Type: query.String},
+		"created_at": {Column: "created_at", Type: query.Time},
+	},
+	DefaultSort: "id",
+}
+
+// Получение списка правил округления
+// @Summary Get all rounding policies
+// @Description Get a paginated list of rounding policies. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
+// @Tags rounding-policies
+// @Accept json
+// @Produce json
+// @Param project_id query int false "Project ID"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -created_at)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.RoundingPolicy]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /rounding-policies [get]
+func GetRoundingPoliciesHandler(c *gin.Context) {
+	params, err := query.Parse(c.Request.URL.Query(), roundingPolicyListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var policies []models.RoundingPolicy
+	total, err := params.Find(config.DB.Model(&models.RoundingPolicy{}), &policies)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(policies, total, params, c.Request.URL))
+}
+
+// Получение правила округления по id
+// @Summary Get rounding policy by ID
+// @Description Get a single rounding policy by its ID
+// @Tags rounding-policies
+// @Accept json
+// @Produce json
+// @Param id path int true "Rounding Policy ID"
+// @Param If-None-Match header string false "ETag of a cached version"
+// @Success 200 {object} models.RoundingPolicy
+// @Header 200 {string} ETag "Resource version"
+// @Success 304
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /rounding-policies/{id} [get]
+func GetRoundingPolicyHandler(c *gin.Context) {
+	id := c.Param("id")
+	var policy models.RoundingPolicy
+
+	result := config.DB.First(&policy, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Rounding policy not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if notModified(c, policy.Version) {
+		return
+	}
+
+	setETag(c, policy.Version)
+	c.JSON(http.StatusOK, policy)
+}
+
+// Создание правила округления
+// @Summary Create a rounding policy
+// @Description Round the time of each task log in reports and invoices up, down or to the nearest increment of minutes; mode none keeps whole minutes.
+// @Description Logs shorter than minimum_minutes are billed as minimum_minutes. A policy with project_id applies to the project, one without it to all other projects; there can be one policy per scope.
+// @Tags rounding-policies
+// @Accept json
+// @Produce json
+// @Param policy body models.RoundingPolicy true "Rounding Policy JSON"
+// @Success 201 {object} models.RoundingPolicy
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /rounding-policies [post]
+func CreateRoundingPolicyHandler(c *gin.Context, validate *validator.Validate) {
+	var policy models.RoundingPolicy
+
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&policy); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if policy.ProjectID != nil && !recordExists(c, &models.Project{}, *policy.ProjectID, "Project not found") {
+		return
+	}
+
+	policy.Version = 1
+	result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&policy)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		roundingPolicyConflict(c)
+		return
+	}
+
+	setETag(c, policy.Version)
+	c.JSON(http.StatusCreated, policy)
+}
+
+// Изменение правила округления
+// @Summary Update a rounding policy
+// @Description Update a rounding policy by ID. Reports use the new policy right away; issued invoices keep their amounts.
+// @Tags rounding-policies
+// @Accept json
+// @Produce json
+// @Param id path int true "Rounding Policy ID"
+// @Param policy body models.RoundingPolicy true "Rounding Policy data"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.RoundingPolicy
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /rounding-policies/{id} [put]
+func UpdateRoundingPolicyHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var policy models.RoundingPolicy
+
+	result := config.DB.First(&policy, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Rounding policy not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, policy.Version) {
+		return
+	}
+
+	policyID, version, createdAt := policy.ID, policy.Version, policy.CreatedAt
+
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&policy); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if policy.ProjectID != nil && !recordExists(c, &models.Project{}, *policy.ProjectID, "Project not found") {
+		return
+	}
+
+	policy.ID = policyID
+	policy.CreatedAt = createdAt
+	policy.Version = version + 1
+
+	result = config.DB.Select("*").Where("version = ?", version).Save(&policy)
+	if isUniqueViolation(result.Error) {
+		roundingPolicyConflict(c)
+		return
+	}
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	setETag(c, policy.Version)
+	c.JSON(http.StatusOK, policy)
+}
+
+// Удаление правила округления
+// @Summary Delete a rounding policy
+// @Description Delete a rounding policy by ID. The project falls back to the global policy, or to whole minutes without one.
+// @Tags rounding-policies
+// @Accept json
+// @Produce json
+// @Param id path int true "Rounding Policy ID"
+// @Param If-Match header string false "Expected ETag"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /rounding-policies/{id} [delete]
+func DeleteRoundingPolicyHandler(c *gin.Context) {
+	id := c.Param("id")
+	var policy models.RoundingPolicy
+
+	result := config.DB.First(&policy, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Rounding policy not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, policy.Version) {
+		return
+	}
+
+	result = config.DB.Where("version = ?", policy.Version).Delete(&policy)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Ответ 409: у области правила уже есть другое правило (уникальный индекс idx_rounding_policy_scope)
+func roundingPolicyConflict(c *gin.Context) {
+	c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Rounding policy for this scope already exists"})
+}
+
+// Округлённые минуты завершённых логов по id лога: правило проекта задачи,
+// иначе общее правило, иначе целые минуты
+func roundTaskLogs(taskLogs []models.TaskLog, tasks map[uint]models.Task) (map[uint]int, error) {
+	logMinutes := make(map[uint]int)
+	if len(taskLogs) == 0 {
+		return logMinutes, nil
+	}
+
+	var policies []models.RoundingPolicy
+	if err := config.DB.Find(&policies).Error; err != nil {
+		return nil, err
+	}
+
+	var global *models.RoundingPolicy
+	projectPolicies := make(map[uint]models.RoundingPolicy)
+	for i, policy := range policies {
+		if policy.ProjectID == nil {
+			global = &policies[i]
+		} else {
+			projectPolicies[*policy.ProjectID] = policy
+		}
+	}
+
+	for _, log := range taskLogs {
+		if log.EndTime.IsZero() {
+			continue
+		}
+
+		policy := global
+		if task := tasks[log.TaskID]; task.ProjectID != nil {
+			if projectPolicy, ok := projectPolicies[*task.ProjectID]; ok {
+				policy = &projectPolicy
+			}
+		}
+
+		logMinutes[log.ID] = roundDuration(log.EndTime.Sub(log.StartTime), policy)
+	}
+
+	return logMinutes, nil
+}
+
+// Длительность в минутах по правилу округления; без правила отбрасываются неполные минуты
+func roundDuration(d time.Duration, policy *models.RoundingPolicy) int {
+	if policy == nil || policy.Mode == models.RoundingNone || policy.Increment <= 0 {
+		return applyMinimum(int(d.Minutes()), d, policy)
+	}
+
+	increment := time.Duration(policy.Increment) * time.Minute
+	var rounded time.Duration
+	switch policy.Mode {
+	case models.RoundingUp:
+		rounded = (d + increment - 1) / increment * increment
+	case models.RoundingDown:
+		rounded = d / increment * increment
+	default:
+		rounded = (d + increment/2) / increment * increment
+	}
+
+	return applyMinimum(int(rounded/time.Minute), d, policy)
+}
+
+// Минимальная оплачиваемая длительность для непустых логов
+func applyMinimum(minutes int, d time.Duration, policy *models.RoundingPolicy) int {
+	if policy != nil && d > 0 && minutes < policy.MinimumMinutes {
+		return policy.MinimumMinutes
+	}
+	return minutes
+}
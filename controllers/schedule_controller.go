@@ -0,0 +1,378 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/ical"
+	"em-test/models"
+	"em-test/query"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// График для пользователей без своего: 8 часов с понедельника по пятницу
+var defaultWorkHours = []float64{8, 8, 8, 8, 8, 0, 0}
+
+// Ограничения импорта праздников: размер файла и длина одного события в днях
+const (
+	holidayImportMaxBytes = 1 << 20
+	holidayEventMaxDays   = 366
+)
+
+// Поля праздника, доступные для фильтрации и сортировки
+var holidayListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":   {Column: "id", Type: query.Int},
+		"date": {Column: "date", Type: query.Time},
+		"name": {Column: "name", Type: query.String, DefaultOp: query.Contains},
+	},
+	DefaultSort: "date",
+}
+
+// Получение рабочего графика пользователя
+// @Summary Get user work schedule
+// @Description Get working hours per weekday, Monday first. Users without a schedule work 8 hours Monday to Friday (version 0).
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.WorkSchedule
+// @Header 200 {string} ETag "Resource version"
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/schedule [get]
+func GetWorkScheduleHandler(c *gin.Context) {
+	id := c.Param("id")
+	var user models.User
+
+	result := config.DB.First(&user, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	schedule, err := workSchedule(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, schedule.Version)
+	c.JSON(http.StatusOK, schedule)
+}
+
+// Изменение рабочего графика пользователя
+// @Summary Set user work schedule
+// @Description Replace working hours per weekday, Monday first
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param schedule body models.WorkSchedule true "Work schedule"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.WorkSchedule
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/schedule [put]
+func SetWorkScheduleHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var user models.User
+
+	result := config.DB.First(&user, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	schedule, err := workSchedule(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if preconditionFailed(c, schedule.Version) {
+		return
+	}
+
+	version, createdAt := schedule.Version, schedule.CreatedAt
+	schedule.Hours = nil
+
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&schedule); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	schedule.UserID = user.ID
+	schedule.CreatedAt = createdAt
+	schedule.Version = version + 1
+
+	if version == 0 {
+		result = config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&schedule)
+	} else {
+		result = config.DB.Select("*").Where("version = ?", version).Save(&schedule)
+	}
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	setETag(c, schedule.Version)
+	c.JSON(http.StatusOK, schedule)
+}
+
+// Получение праздников
+// @Summary Get holidays
+// @Description Get a paginated list of holidays ordered by date. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param date[gt] query string false "Holidays after the date (RFC 3339)"
+// @Param date[lt] query string false "Holidays before the date (RFC 3339)"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -date)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.Holiday]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /holidays [get]
+func GetHolidaysHandler(c *gin.Context) {
+	params, err := query.Parse(c.Request.URL.Query(), holidayListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var holidays []models.Holiday
+	total, err := params.Find(config.DB.Model(&models.Holiday{}), &holidays)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(holidays, total, params, c.Request.URL))
+}
+
+// Добавление праздника
+// @Summary Create a holiday
+// @Description Add a non-working day for all users. The time of day is ignored.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param holiday body models.Holiday true "Holiday JSON"
+// @Success 201 {object} models.Holiday
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /holidays [post]
+func CreateHolidayHandler(c *gin.Context, validate *validator.Validate) {
+	var holiday models.Holiday
+
+	if err := c.ShouldBindJSON(&holiday); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&holiday); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	holiday.Date = startOfDay(holiday.Date)
+	holiday.Version = 1
+
+	result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&holiday)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Holiday already exists for this date"})
+		return
+	}
+
+	setETag(c, holiday.Version)
+	c.JSON(http.StatusCreated, holiday)
+}
+
+// Импорт праздников из iCalendar
+// @Summary Import holidays
+// @Description Import holidays from an iCalendar (.ics) file. Every day of every event becomes a holiday named after the event summary; existing holidays on the same dates are renamed.
+// @Description Recurring events (RRULE) are not expanded.
+// @Tags schedules
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "iCalendar file"
+// @Success 200 {array} models.Holiday
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /holidays/import [post]
+func ImportHolidaysHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, holidayImportMaxBytes+multipartOverhead)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{Error: fmt.Sprintf("File is larger than %d bytes", holidayImportMaxBytes)})
+		} else {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "file is required"})
+		}
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	defer file.Close()
+
+	events, err := ical.Parse(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid iCalendar file: " + err.Error()})
+		return
+	}
+
+	var holidays []models.Holiday
+	var dates []time.Time
+	for _, event := range events {
+		if event.End.After(event.Start.AddDate(0, 0, holidayEventMaxDays)) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: fmt.Sprintf("Event %q is longer than %d days", event.Summary, holidayEventMaxDays)})
+			return
+		}
+		for day := event.Start; day.Before(event.End); day = day.AddDate(0, 0, 1) {
+			holidays = append(holidays, models.Holiday{Date: day, Name: event.Summary, Version: 1})
+			dates = append(dates, day)
+		}
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range holidays {
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "date"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"name":       holidays[i].Name,
+					"version":    gorm.Expr("holidays.version + 1"),
+					"updated_at": time.Now(),
+				}),
+			}).Create(&holidays[i]).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		holidays = []models.Holiday{}
+		if len(dates) == 0 {
+			return nil
+		}
+		return tx.Where("date IN ?", dates).Order("date").Find(&holidays).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, holidays)
+}
+
+// Удаление праздника
+// @Summary Delete a holiday
+// @Description Delete a holiday by ID
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param id path int true "Holiday ID"
+// @Param If-Match header string false "Expected ETag"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /holidays/{id} [delete]
+func DeleteHolidayHandler(c *gin.Context) {
+	id := c.Param("id")
+	var holiday models.Holiday
+
+	result := config.DB.First(&holiday, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Holiday not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, holiday.Version) {
+		return
+	}
+
+	result = config.DB.Where("version = ?", holiday.Version).Delete(&holiday)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Рабочий график пользователя или график по умолчанию с версией 0
+func workSchedule(userID uint) (models.WorkSchedule, error) {
+	var schedule models.WorkSchedule
+
+	result := config.DB.Where("user_id = ?", userID).Limit(1).Find(&schedule)
+	if result.Error != nil {
+		return schedule, result.Error
+	}
+	if result.RowsAffected == 0 {
+		schedule = models.WorkSchedule{UserID: userID, Hours: append([]float64(nil), defaultWorkHours...)}
+	}
+
+	return schedule, nil
+}
+
+// Начало дня в UTC
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
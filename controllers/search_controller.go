@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"em-test/search"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Полнотекстовый поиск по задачам и пользователям
+// @Summary Search tasks and users
+// @Description Full-text search over task titles and descriptions and user names and addresses, ordered by relevance. Matches are wrapped in <b></b>, the rest of the title and snippet is HTML-escaped.
+// @Tags search
+// @Accept json
+// @Produce json
+// @Param q query string true "Search query"
+// @Param type query string false "Comma-separated document types" Enums(task, user)
+// @Param limit query int false "Maximum number of results (max 100)"
+// @Success 200 {array} models.SearchResult
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /search [get]
+func SearchHandler(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "q is required"})
+		return
+	}
+
+	var types []string
+	if typeStr := c.Query("type"); typeStr != "" {
+		for _, t := range strings.Split(typeStr, ",") {
+			t = strings.TrimSpace(t)
+			if t != search.TypeTask && t != search.TypeUser {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid type: " + t})
+				return
+			}
+			types = append(types, t)
+		}
+	}
+
+	limit := defaultSearchLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 1 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid limit format"})
+			return
+		}
+		limit = min(l, maxSearchLimit)
+	}
+
+	results, err := search.New(config.DB).Search(q, types, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Получение списка тегов
+// @Summary Get all tags
+// @Description Get all tags used on tasks and task logs
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.Tag
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tags [get]
+func GetTagsHandler(c *gin.Context) {
+	var tags []models.Tag
+
+	result := config.DB.Order("name").Find(&tags)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// Замена тегов TaskLog
+// @Summary Set task log tags
+// @Description Replace tags of a task log. Tags are lowercased, unknown tags are created.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "Task Log ID"
+// @Param tags body models.TagsRequest true "Tags JSON"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.TaskLog
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasklogs/{id}/tags [put]
+func SetTaskLogTagsHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var taskLog models.TaskLog
+
+	result := config.DB.First(&taskLog, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task log not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, taskLog.Version) {
+		return
+	}
+
+	if taskLogLocked(c, taskLog) {
+		return
+	}
+
+	var request models.TagsRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&request); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	version := taskLog.Version
+	taskLog.Version = version + 1
+	taskLog.Tags = normalizeTags(request.Tags)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Select("*").Where("version = ?", version).Save(&taskLog)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVersionConflict
+		}
+
+		return setTaskLogTags(tx, taskLog.ID, taskLog.Tags)
+	})
+	if err == errVersionConflict {
+		versionConflict(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, taskLog.Version)
+	c.JSON(http.StatusOK, taskLog)
+}
+
+// Приведение тегов к нижнему регистру, удаление пустых и повторов
+func normalizeTags(names []string) []string {
+	seen := make(map[string]bool)
+	tags := []string{}
+
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+
+	sort.Strings(tags)
+	return tags
+}
+
+// Получение тегов по именам с созданием отсутствующих
+func ensureTags(tx *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	if len(names) == 0 {
+		return tags, nil
+	}
+
+	created := make([]models.Tag, len(names))
+	for i, name := range names {
+		created[i] = models.Tag{Name: name}
+	}
+
+	if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).Create(&created).Error; err != nil {
+		return nil, err
+	}
+
+	err := tx.Where("name IN ?", names).Find(&tags).Error
+	return tags, err
+}
+
+func setTaskTags(tx *gorm.DB, taskID uint, names []string) error {
+	tags, err := ensureTags(tx, names)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Where("task_id = ?", taskID).Delete(&models.TaskTag{}).Error; err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	links := make([]models.TaskTag, len(tags))
+	for i, tag := range tags {
+		links[i] = models.TaskTag{TaskID: taskID, TagID: tag.ID}
+	}
+	return tx.Create(&links).Error
+}
+
+func setTaskLogTags(tx *gorm.DB, taskLogID uint, names []string) error {
+	tags, err := ensureTags(tx, names)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Where("task_log_id = ?", taskLogID).Delete(&models.TaskLogTag{}).Error; err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	links := make([]models.TaskLogTag, len(tags))
+	for i, tag := range tags {
+		links[i] = models.TaskLogTag{TaskLogID: taskLogID, TagID: tag.ID}
+	}
+	return tx.Create(&links).Error
+}
+
+type tagLink struct {
+	OwnerID uint
+	TagID   uint
+	Name    string
+}
+
+// Теги записей из таблицы связей table по колонке column
+func loadTagLinks(table, column string, ids []uint) ([]tagLink, error) {
+	var links []tagLink
+	if len(ids) == 0 {
+		return links, nil
+	}
+
+	err := config.DB.Table(table).
+		Select(table+"."+column+" AS owner_id, tags.id AS tag_id, tags.name").
+		Joins("JOIN tags ON tags.id = "+table+".tag_id").
+		Where(table+"."+column+" IN ?", ids).
+		Order("tags.name").
+		Scan(&links).Error
+	return links, err
+}
+
+// Заполнение поля Tags у задач
+func attachTaskTags(tasks []models.Task) error {
+	ids := make([]uint, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+
+	links, err := loadTagLinks("task_tags", "task_id", ids)
+	if err != nil {
+		return err
+	}
+
+	tags := make(map[uint][]string)
+	for _, link := range links {
+		tags[link.OwnerID] = append(tags[link.OwnerID], link.Name)
+	}
+	for i := range tasks {
+		tasks[i].Tags = tags[tasks[i].ID]
+		if tasks[i].Tags == nil {
+			tasks[i].Tags = []string{}
+		}
+	}
+
+	return nil
+}
+
+// Заполнение поля Tags у TaskLog
+func attachTaskLogTags(taskLogs []models.TaskLog) error {
+	ids := make([]uint, len(taskLogs))
+	for i, taskLog := range taskLogs {
+		ids[i] = taskLog.ID
+	}
+
+	links, err := loadTagLinks("task_log_tags", "task_log_id", ids)
+	if err != nil {
+		return err
+	}
+
+	tags := make(map[uint][]string)
+	for _, link := range links {
+		tags[link.OwnerID] = append(tags[link.OwnerID], link.Name)
+	}
+	for i := range taskLogs {
+		taskLogs[i].Tags = tags[taskLogs[i].ID]
+		if taskLogs[i].Tags == nil {
+			taskLogs[i].Tags = []string{}
+		}
+	}
+
+	return nil
+}
+
+// Фильтр по тегам: запись должна иметь все теги из параметров tag
+func filterByTags(c *gin.Context, db *gorm.DB, table, column string) *gorm.DB {
+	for _, name := range normalizeTags(c.QueryArray("tag")) {
+		tagged := config.DB.Table(table).
+			Select(table+"."+column).
+			Joins("JOIN tags ON tags.id = "+table+".tag_id").
+			Where("tags.name = ?", name)
+		db = db.Where("id IN (?)", tagged)
+	}
+
+	return db
+}
+
+func loadTaskTags(task *models.Task) error {
+	tasks := []models.Task{*task}
+	if err := attachTaskTags(tasks); err != nil {
+		return err
+	}
+	task.Tags = tasks[0].Tags
+	return nil
+}
+
+func loadTaskLogTags(taskLog *models.TaskLog) error {
+	taskLogs := []models.TaskLog{*taskLog}
+	if err := attachTaskLogTags(taskLogs); err != nil {
+		return err
+	}
+	taskLog.Tags = taskLogs[0].Tags
+	return nil
+}
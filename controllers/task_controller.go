@@ -3,6 +3,7 @@ package controllers
 import (
 	"em-test/config"
 	"em-test/models"
+	"em-test/query"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -10,35 +11,74 @@ import (
 	"gorm.io/gorm"
 )
 
+// Поля задачи, доступные для фильтрации и сортировки
+var taskListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":               {Column: "id", Type: query.Int},
+		"project_id":       {Column: "project_id", Type: query.Int},
+		"parent_id":        {Column: "parent_id", Type: query.Int},
+		"status":           {Column: "status", Type: query.String},
+		"estimate_minutes": {Column: "estimate_minutes", Type: query.Int},
+		"title":            {Column: "title", Type: query.String, DefaultOp: query.Contains},
+		"description":      {Column: "description", Type: query.String, DefaultOp: query.Contains},
+		"created_at":       {Column: "created_at", Type: query.Time},
+		"updated_at":       {Column: "updated_at", Type: query.Time},
+	},
+}
+
 // Получение списка всех задач
 // @Summary Get all tasks
-// @Description Get a list of all tasks
+// @Description Get a paginated list of tasks. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
 // @Tags tasks
 // @Accept json
 // @Produce json
-// @Success 200 {array} models.User
+// @Param project_id query int false "Project ID"
+// @Param parent_id query int false "Parent task ID"
+// @Param status query string false "Status"
+// @Param title query string false "Title contains"
+// @Param tag query []string false "Tags the task must have" collectionFormat(multi)
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -created_at,title)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.Task]
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /tasks [get]
 func GetTasksHandler(c *gin.Context) {
+	params, err := query.Parse(c.Request.URL.Query(), taskListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	db := filterByTags(c, config.DB.Model(&models.Task{}), "task_tags", "task_id")
+
 	var tasks []models.Task
-	result := config.DB.Find(&tasks)
+	total, err := params.Find(db, &tasks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+	if err := attachTaskTags(tasks); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, tasks)
+	c.JSON(http.StatusOK, query.NewPage(tasks, total, params, c.Request.URL))
 }
 
 // Получение задачи по id
 // @Summary Get task by ID
-// @Description Get a single task by its ID
+// @Description Get a single task by its ID, including the number of comments. Adding or deleting a comment changes the task version and ETag.
 // @Tags tasks
 // @Accept json
 // @Produce json
 // @Param id path int true "Task ID"
+// @Param If-None-Match header string false "ETag of a cached version"
 // @Success 200 {object} models.Task
+// @Header 200 {string} ETag "Resource version"
+// @Success 304
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /tasks/{id} [get]
@@ -57,6 +97,23 @@ func GetTaskHandler(c *gin.Context) {
     return
   }
 
+	if notModified(c, task.Version) {
+		return
+	}
+
+	if err := loadTaskTags(&task); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var comments int64
+	if err := config.DB.Model(&models.Comment{}).Where("task_id = ? AND deleted = ?", task.ID, false).Count(&comments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	task.CommentCount = &comments
+
+	setETag(c, task.Version)
 	c.JSON(http.StatusOK, task)
 }
 
@@ -91,12 +148,143 @@ func CreateTaskHandler(c *gin.Context, validate *validator.Validate) {
 		return
 	}
 
-	result := config.DB.Create(&task)
+	if task.ProjectID != nil && !recordExists(c, &models.Project{}, *task.ProjectID, "Project not found") {
+		return
+	}
 
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+	if task.ParentID != nil && !validParent(c, 0, *task.ParentID) {
 		return
 	}
 
+	task.Status = config.TaskWorkflow.Initial
+	task.CommentCount = nil
+	task.Version = 1
+	task.Tags = normalizeTags(task.Tags)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		if err := setTaskTags(tx, task.ID, task.Tags); err != nil {
+			return err
+		}
+		return recordTaskEvent(tx, models.EventTaskCreated, task)
+	})
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, task.Version)
 	c.JSON(http.StatusCreated, task)
+}
+
+// Изменение задачи
+// @Summary Update a task
+// @Description Update task details by ID. Status is changed only through transitions, tags are kept when omitted.
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param task body models.Task true "Task data"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.Task
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/{id} [put]
+func UpdateTaskHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var task models.Task
+
+	result := config.DB.First(&task, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, task.Version) {
+		return
+	}
+
+	// Теги задачи меняются только со сменой версии, поэтому загруженные заранее
+	// теги актуальны, если в запросе их нет
+	if err := loadTaskTags(&task); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	taskID, version, status, tags, createdAt := task.ID, task.Version, task.Status, task.Tags, task.CreatedAt
+	task.Tags = nil
+
+	if err := c.ShouldBindJSON(&task); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&task); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if task.ProjectID != nil && !recordExists(c, &models.Project{}, *task.ProjectID, "Project not found") {
+		return
+	}
+
+	if task.ParentID != nil && !validParent(c, taskID, *task.ParentID) {
+		return
+	}
+
+	task.ID = taskID
+	task.CreatedAt = createdAt
+	task.Status = status
+	task.CommentCount = nil
+	task.Version = version + 1
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Select("*").Where("version = ?", version).Save(&task)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVersionConflict
+		}
+
+		// Теги заменяются, только если они переданы в запросе
+		if task.Tags != nil {
+			task.Tags = normalizeTags(task.Tags)
+			if err := setTaskTags(tx, task.ID, task.Tags); err != nil {
+				return err
+			}
+		} else {
+			task.Tags = tags
+		}
+
+		return recordTaskEvent(tx, models.EventTaskUpdated, task)
+	})
+	if err == errVersionConflict {
+		versionConflict(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, task.Version)
+	c.JSON(http.StatusOK, task)
 }
\ No newline at end of file
@@ -3,6 +3,9 @@ package controllers
 import (
 	"em-test/config"
 	"em-test/models"
+	"em-test/query"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -11,25 +14,124 @@ import (
 	"gorm.io/gorm"
 )
 
+// Поля TaskLog, доступные для фильтрации и сортировки
+var taskLogListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":           {Column: "id", Type: query.Int},
+		"task_id":      {Column: "task_id", Type: query.Int},
+		"user_id":      {Column: "user_id", Type: query.Int},
+		"billable":     {Column: "billable", Type: query.Bool},
+		"auto_stopped": {Column: "auto_stopped", Type: query.Bool},
+		"start_time":   {Column: "start_time", Type: query.Time},
+		"end_time":     {Column: "end_time", Type: query.Time},
+		"created_at":   {Column: "created_at", Type: query.Time},
+		"updated_at":   {Column: "updated_at", Type: query.Time},
+	},
+	CursorSort: "-start_time",
+}
+
+// Фильтры TaskLog по состоянию таймера и пересечению с периодом from..to.
+// Незавершённый лог считается длящимся до текущего момента.
+func filterTaskLogs(c *gin.Context, db *gorm.DB) (*gorm.DB, error) {
+	switch state := c.Query("state"); state {
+	case "":
+	case "running":
+		db = db.Where("end_time = ?", time.Time{})
+	case "completed":
+		db = db.Where("end_time <> ?", time.Time{})
+	default:
+		return nil, fmt.Errorf("invalid state: %s", state)
+	}
+
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err := query.ParseTime(fromStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from format")
+		}
+		db = db.Where("(end_time > ? OR end_time = ?)", from, time.Time{})
+	}
+
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := query.ParseTime(toStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to format")
+		}
+		// Дата без времени включает весь этот день
+		if _, err := time.Parse("2006-01-02", toStr); err == nil {
+			to = to.AddDate(0, 0, 1)
+		}
+		db = db.Where("start_time < ?", to)
+	}
+
+	return db, nil
+}
+
 // Получение всех TaskLogs
 // @Summary Get all task logs
-// @Description Get a list of all task logs
+// @Description Get a paginated list of task logs. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
+// @Description Passing cursor (empty for the first page) switches to keyset pagination, newest logs first, and returns items with next_cursor instead of page and total.
 // @Tags tasklogs
 // @Accept json
 // @Produce json
-// @Success 200 {array} models.TaskLog
+// @Param user_id query int false "User ID"
+// @Param task_id query int false "Task ID"
+// @Param state query string false "Timer state" Enums(running, completed)
+// @Param billable query bool false "Billable"
+// @Param auto_stopped query bool false "Stopped automatically and awaiting review"
+// @Param from query string false "Logs overlapping the period starting at (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Logs overlapping the period ending at (RFC3339, or YYYY-MM-DD to include that whole day)"
+// @Param tag query []string false "Tags the log must have" collectionFormat(multi)
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -start_time)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Param cursor query string false "Opaque cursor from next_cursor"
+// @Success 200 {object} models.Page[models.TaskLog]
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /tasklogs [get]
 func GetTaskLogsHandler(c *gin.Context) {
-	var tasksLogs []models.TaskLog
-	result := config.DB.Find(&tasksLogs)
+	params, err := query.Parse(c.Request.URL.Query(), taskLogListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+	db, err := filterTaskLogs(c, config.DB.Model(&models.TaskLog{}))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	db = filterByTags(c, db, "task_log_tags", "task_log_id")
+
+	if params.Keyset {
+		taskLogs, next, err := query.FindAfter[models.TaskLog](db, params)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		if err := attachTaskLogTags(taskLogs); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, query.NewCursorPage(taskLogs, next, params, c.Request.URL))
+		return
+	}
+
+	var taskLogs []models.TaskLog
+	total, err := params.Find(db, &taskLogs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := attachTaskLogTags(taskLogs); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, tasksLogs)
+	c.JSON(http.StatusOK, query.NewPage(taskLogs, total, params, c.Request.URL))
 }
 
 // Получение TaskLog по id
@@ -39,7 +141,10 @@ func GetTaskLogsHandler(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Task Log ID"
+// @Param If-None-Match header string false "ETag of a cached version"
 // @Success 200 {object} models.TaskLog
+// @Header 200 {string} ETag "Resource version"
+// @Success 304
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /tasklogs/{id} [get]
@@ -58,18 +163,31 @@ func GetTaskLogHandler(c *gin.Context) {
 		return
 	}
 
+	if notModified(c, taskLog.Version) {
+		return
+	}
+
+	if err := loadTaskLogTags(&taskLog); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, taskLog.Version)
 	c.JSON(http.StatusOK, taskLog)
 }
 
 // Создание нового TaskLog и установка StartTime
 // @Summary Create a new task log
-// @Description Create a new task log with the input payload and set the start time
+// @Description Create a new task log with the input payload and set the start time. Logs are billable unless billable is false.
 // @Tags tasklogs
 // @Accept json
 // @Produce json
 // @Param tasklog body models.TaskLog true "Task Log JSON"
 // @Success 201 {object} models.TaskLog
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /tasklogs [post]
 func CreateAndStartTaskLog(c *gin.Context, validate *validator.Validate) {
@@ -92,14 +210,66 @@ func CreateAndStartTaskLog(c *gin.Context, validate *validator.Validate) {
 		return
 	}
 
-	taskLog.StartTime = time.Now()
-	result := config.DB.Create(&taskLog)
-
+	var task models.Task
+	result := config.DB.First(&task, taskLog.TaskID)
 	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
 		return
 	}
 
+	if config.TaskWorkflow.IsTerminal(task.Status) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Task is " + task.Status + ", timers cannot be started"})
+		return
+	}
+
+	if config.RestrictTimersToAssignees {
+		var assigned int64
+		err := config.DB.Model(&models.TaskAssignee{}).Where("task_id = ? AND user_id = ?", taskLog.TaskID, taskLog.UserID).Count(&assigned).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		if assigned == 0 {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "User is not assigned to the task"})
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	if timesheetLocked(c, taskLog.UserID, now) || periodLocked(c, taskLog.UserID, now) {
+		return
+	}
+
+	taskLog.StartTime = now
+	taskLog.InvoiceID = nil
+	taskLog.AutoStopped = false
+	taskLog.Version = 1
+	if taskLog.Billable == nil {
+		billable := true
+		taskLog.Billable = &billable
+	}
+	taskLog.Tags = normalizeTags(taskLog.Tags)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&taskLog).Error; err != nil {
+			return err
+		}
+		if err := setTaskLogTags(tx, taskLog.ID, taskLog.Tags); err != nil {
+			return err
+		}
+		return recordTimerEvent(tx, models.EventTimerStarted, taskLog)
+	})
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, taskLog.Version)
 	c.JSON(http.StatusCreated, taskLog)
 }
 
@@ -110,8 +280,13 @@ func CreateAndStartTaskLog(c *gin.Context, validate *validator.Validate) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Task Log ID"
+// @Param If-Match header string false "Expected ETag"
 // @Success 200 {object} models.TaskLog
+// @Header 200 {string} ETag "Resource version"
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /tasklogs/{id}/complete [put]
 func CompleteTaskLogHandler(c *gin.Context) {
@@ -128,11 +303,257 @@ func CompleteTaskLogHandler(c *gin.Context) {
 		return
 	}
 
-	taskLog.EndTime = time.Now()
-	if err := config.DB.Save(&taskLog).Error; err != nil {
+	if preconditionFailed(c, taskLog.Version) {
+		return
+	}
+
+	if taskLogLocked(c, taskLog) {
+		return
+	}
+
+	// Теги лога меняются только со сменой версии, поэтому загруженные заранее
+	// теги попадут в событие актуальными
+	if err := loadTaskLogTags(&taskLog); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	version := taskLog.Version
+	taskLog.EndTime = time.Now().UTC()
+	taskLog.Version = version + 1
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Select("*").Where("version = ?", version).Save(&taskLog)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVersionConflict
+		}
+
+		return recordTimerEvent(tx, models.EventTimerStopped, taskLog)
+	})
+	if err == errVersionConflict {
+		versionConflict(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, taskLog.Version)
+	c.JSON(http.StatusOK, taskLog)
+}
+
+// Подтверждение автоматически остановленного лога
+// @Summary Acknowledge an auto-stopped task log
+// @Description Clear the auto_stopped review flag of a task log stopped by the auto-stop job, keeping its times. To correct the times edit the log instead.
+// @Tags tasklogs
+// @Accept json
+// @Produce json
+// @Param id path int true "Task Log ID"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.TaskLog
+// @Header 200 {string} ETag "Resource version"
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasklogs/{id}/acknowledge [put]
+func AcknowledgeTaskLogHandler(c *gin.Context) {
+	id := c.Param("id")
+	var taskLog models.TaskLog
+
+	result := config.DB.First(&taskLog, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task log not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, taskLog.Version) {
+		return
+	}
+
+	if !taskLog.AutoStopped {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Task log is not awaiting review"})
+		return
+	}
+
+	// Время лога не меняется, поэтому закрытые периоды и табели не проверяются
+	result = config.DB.Model(&taskLog).Where("version = ?", taskLog.Version).
+		Updates(map[string]interface{}{"auto_stopped": false, "version": taskLog.Version + 1})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	if err := loadTaskLogTags(&taskLog); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, taskLog.Version)
+	c.JSON(http.StatusOK, taskLog)
+}
+
+// Получение запущенного таймера пользователя
+// @Summary Get user's running timer
+// @Description Get the task log that is currently running for the user
+// @Tags tasklogs
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.TaskLog
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id}/current-timer [get]
+func GetCurrentTimerHandler(c *gin.Context) {
+	id := c.Param("id")
+	var user models.User
+
+	result := config.DB.First(&user, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	var taskLog models.TaskLog
+	result = config.DB.Where("user_id = ? AND end_time = ?", user.ID, time.Time{}).Order("start_time DESC").First(&taskLog)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "No running timer"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if err := loadTaskLogTags(&taskLog); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	setETag(c, taskLog.Version)
 	c.JSON(http.StatusOK, taskLog)
-}
\ No newline at end of file
+}
+
+// Ответ 409 или 423, если лог нельзя изменять: он вошёл в счёт,
+// его неделя отправлена в табеле или период закрыт
+func taskLogLocked(c *gin.Context, taskLog models.TaskLog) bool {
+	return invoicedTaskLog(c, taskLog) ||
+		timesheetLocked(c, taskLog.UserID, taskLog.StartTime) ||
+		periodLocked(c, taskLog.UserID, taskLog.StartTime)
+}
+
+// Остановка забытых таймеров: длящихся дольше config.TimerMaxDuration
+// или переживших конец рабочего дня пользователя. Вызывается планировщиком.
+// Лог останавливается условным обновлением по версии, поэтому при запуске
+// на нескольких репликах каждый таймер остановит только одна из них.
+// Таймеры в закрытых периодах и отправленных табелях не трогаются, как и при
+// ручном завершении; ошибка одного лога не мешает остановить остальные.
+func AutoStopTimers(now time.Time) error {
+	var taskLogs []models.TaskLog
+	if err := config.DB.Where("end_time = ?", time.Time{}).Find(&taskLogs).Error; err != nil {
+		return err
+	}
+	if len(taskLogs) == 0 {
+		return nil
+	}
+
+	userIDs := make(map[uint]int)
+	for _, log := range taskLogs {
+		userIDs[log.UserID] = 0
+	}
+
+	var found []models.User
+	if err := config.DB.Find(&found, mapKeys(userIDs)).Error; err != nil {
+		return err
+	}
+	users := make(map[uint]models.User)
+	for _, user := range found {
+		users[user.ID] = user
+	}
+
+	var errs []error
+	for _, log := range taskLogs {
+		stopAt := autoStopTime(log, users[log.UserID])
+		if stopAt.IsZero() || stopAt.After(now) {
+			continue
+		}
+
+		if err := autoStopTimer(log, stopAt); err != nil {
+			errs = append(errs, fmt.Errorf("task log %d: %w", log.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func autoStopTimer(log models.TaskLog, stopAt time.Time) error {
+	if _, locked, err := periodLockAt(log.UserID, log.StartTime); err != nil || locked {
+		return err
+	}
+	if _, locked, err := lockingTimesheetAt(log.UserID, log.StartTime); err != nil || locked {
+		return err
+	}
+
+	if err := loadTaskLogTags(&log); err != nil {
+		return err
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.TaskLog{}).
+			Where("id = ? AND version = ? AND end_time = ?", log.ID, log.Version, time.Time{}).
+			Updates(map[string]interface{}{"end_time": stopAt.UTC(), "auto_stopped": true, "version": gorm.Expr("version + 1")})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		log.EndTime = stopAt.UTC()
+		log.AutoStopped = true
+		log.Version++
+		return recordTimerEvent(tx, models.EventTimerStopped, log)
+	})
+}
+
+// Момент автоматической остановки таймера; нулевое время, если ограничений нет.
+// Конец рабочего дня берётся в часовом поясе пользователя.
+func autoStopTime(taskLog models.TaskLog, user models.User) time.Time {
+	var stopAt time.Time
+	if config.TimerMaxDuration > 0 {
+		stopAt = taskLog.StartTime.Add(config.TimerMaxDuration)
+	}
+
+	if user.WorkdayEnd != nil {
+		end, err := time.Parse("15:04", *user.WorkdayEnd)
+		if err != nil {
+			return stopAt
+		}
+
+		// Таймер, запущенный после конца рабочего дня (вечерняя или сверхурочная
+		// работа), идёт до конца следующего рабочего дня
+		start := taskLog.StartTime.In(userLocation(user))
+		workdayEnd := time.Date(start.Year(), start.Month(), start.Day(), end.Hour(), end.Minute(), 0, 0, start.Location())
+		if !workdayEnd.After(start) {
+			workdayEnd = time.Date(start.Year(), start.Month(), start.Day()+1, end.Hour(), end.Minute(), 0, 0, start.Location())
+		}
+		if stopAt.IsZero() || workdayEnd.Before(stopAt) {
+			stopAt = workdayEnd
+		}
+	}
+
+	return stopAt
+}
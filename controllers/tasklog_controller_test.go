@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"testing"
+	"time"
+)
+
+func TestAutoStopTime(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	workdayEnd := "18:00"
+	user := models.User{WorkdayEnd: &workdayEnd, TimeZone: "Europe/Moscow"}
+
+	tests := []struct {
+		name        string
+		start       time.Time
+		user        models.User
+		maxDuration time.Duration
+		want        time.Time
+	}{
+		{
+			name:  "started during the workday",
+			start: time.Date(2026, 3, 5, 9, 0, 0, 0, moscow),
+			user:  user,
+			want:  time.Date(2026, 3, 5, 18, 0, 0, 0, moscow),
+		},
+		{
+			name:  "started after the workday runs until the next workday end",
+			start: time.Date(2026, 3, 5, 20, 30, 0, 0, moscow),
+			user:  user,
+			want:  time.Date(2026, 3, 6, 18, 0, 0, 0, moscow),
+		},
+		{
+			name:  "started exactly at the workday end",
+			start: time.Date(2026, 3, 5, 18, 0, 0, 0, moscow),
+			user:  user,
+			want:  time.Date(2026, 3, 6, 18, 0, 0, 0, moscow),
+		},
+		{
+			name:        "maximum duration comes first",
+			start:       time.Date(2026, 3, 5, 20, 30, 0, 0, moscow),
+			user:        user,
+			maxDuration: 4 * time.Hour,
+			want:        time.Date(2026, 3, 6, 0, 30, 0, 0, moscow),
+		},
+		{
+			name:        "without a workday end",
+			start:       time.Date(2026, 3, 5, 20, 30, 0, 0, moscow),
+			maxDuration: 12 * time.Hour,
+			want:        time.Date(2026, 3, 6, 8, 30, 0, 0, moscow),
+		},
+		{
+			name:  "no limits",
+			start: time.Date(2026, 3, 5, 20, 30, 0, 0, moscow),
+		},
+	}
+
+	previous := config.TimerMaxDuration
+	t.Cleanup(func() { config.TimerMaxDuration = previous })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.TimerMaxDuration = tt.maxDuration
+			got := autoStopTime(models.TaskLog{StartTime: tt.start.UTC()}, tt.user)
+			if !got.Equal(tt.want) {
+				t.Errorf("autoStopTime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Таймер, запущенный вечером, не останавливается на ближайшем запуске планировщика
+func TestAutoStopTimersKeepsEveningTimers(t *testing.T) {
+	setupTestDB(t, &models.User{}, &models.TaskLog{}, &models.Tag{}, &models.TaskLogTag{},
+		&models.PeriodLock{}, &models.Timesheet{}, &models.OutboxEvent{})
+
+	previous := config.TimerMaxDuration
+	config.TimerMaxDuration = 0
+	t.Cleanup(func() { config.TimerMaxDuration = previous })
+
+	workdayEnd := "18:00"
+	user := models.User{Name: "A", Surname: "B", Patronymic: "C", Address: "D", PassportNumber: "1234 567890", WorkdayEnd: &workdayEnd, TimeZone: "UTC"}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)
+	forgotten := models.TaskLog{TaskID: 1, UserID: user.ID, StartTime: day.Add(10 * time.Hour), Version: 1}
+	evening := models.TaskLog{TaskID: 1, UserID: user.ID, StartTime: day.Add(19 * time.Hour), Version: 1}
+	for _, log := range []*models.TaskLog{&forgotten, &evening} {
+		if err := config.DB.Create(log).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stopped := func(id uint) models.TaskLog {
+		var log models.TaskLog
+		if err := config.DB.First(&log, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		return log
+	}
+
+	if err := AutoStopTimers(day.Add(19*time.Hour + time.Minute)); err != nil {
+		t.Fatalf("AutoStopTimers: %v", err)
+	}
+	if log := stopped(forgotten.ID); !log.AutoStopped || !log.EndTime.Equal(day.Add(18*time.Hour)) {
+		t.Errorf("timer started at 10:00 ended at %v (auto_stopped %v), want 18:00", log.EndTime, log.AutoStopped)
+	}
+	if log := stopped(evening.ID); !log.EndTime.IsZero() {
+		t.Errorf("timer started at 19:00 was stopped at %v", log.EndTime)
+	}
+
+	if err := AutoStopTimers(day.AddDate(0, 0, 1).Add(18*time.Hour + time.Minute)); err != nil {
+		t.Fatalf("AutoStopTimers: %v", err)
+	}
+	if log := stopped(evening.ID); !log.EndTime.Equal(day.AddDate(0, 0, 1).Add(18 * time.Hour)) {
+		t.Errorf("timer started at 19:00 ended at %v, want 18:00 the next day", log.EndTime)
+	}
+}
@@ -13,13 +13,23 @@ import (
 
 // Получение трудозатрат по пользователю за период
 // @Summary Get user task times for a period
-// @Description Get task times spent by a user for a given period, sorted by time spent in descending order
+// @Description Get task times spent by a user for a given period, sorted by time spent in descending order.
+// @Description Hours and minutes are the time logged on the task itself, total_hours and total_minutes also include its subtasks; parents without own time are listed too.
+// @Description With group_by=project or group_by=client the times are rolled up and returned as models.GroupTime (tasks without a project are grouped under id 0).
+// @Description With group_by=tag each log counts towards its own tags and the tags of its task, so a log with several tags appears in several groups; untagged logs are grouped under id 0.
+// @Description Each log is also rounded by the rounding policy of its project or the global one; rounded_* fields hold the rounded time and cost is calculated from it.
+// @Description Cost is calculated for billable logs from the hourly rate in effect when the log started and is listed per currency; logs without a matching rate have no cost.
 // @Tags tasktimes
 // @Accept json
 // @Produce json
 // @Param user_id query int true "User ID"
 // @Param start_date query string true "Start Date (YYYY-MM-DD)"
-// @Param end_date query string true "End Date (YYYY-MM-DD)"
+// @Param end_date query string true "End Date (YYYY-MM-DD), inclusive"
+// @Param project_id query int false "Project ID"
+// @Param client_id query int false "Client ID"
+// @Param group_by query string false "Grouping" Enums(task, project, client, tag)
+// @Param approved query bool false "Only time from approved timesheets"
+// @Param tz query string false "Time zone of the dates (e.g. Asia/Vladivostok), defaults to the user's time zone or UTC"
 // @Success 200 {array} models.TaskTime
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
@@ -28,77 +38,400 @@ func GetUserTaskTimes(c *gin.Context) {
 	userIDStr := c.Query("user_id")
 	startDateStr := c.Query("start_date")
 	endDateStr := c.Query("end_date")
+	groupBy := c.DefaultQuery("group_by", "task")
 
 	if userIDStr == "" || startDateStr == "" || endDateStr == "" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "user_id, start_date, and end_date are required"})
 		return
 	}
 
-	userID, err := strconv.Atoi(userIDStr)
+	userID, err := strconv.ParseUint(userIDStr, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid user_id format"})
 		return
 	}
 
-	startDate, err := time.Parse("2006-01-02", startDateStr)
+	if groupBy != "task" && groupBy != "project" && groupBy != "client" && groupBy != "tag" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid group_by value"})
+		return
+	}
+
+	loc, ok := reportLocation(c, uint(userID))
+	if !ok {
+		return
+	}
+
+	startDate, err := time.ParseInLocation("2006-01-02", startDateStr, loc)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid start_date format"})
 		return
 	}
 
-	endDate, err := time.Parse("2006-01-02", endDateStr)
+	endDate, err := time.ParseInLocation("2006-01-02", endDateStr, loc)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid end_date format"})
 		return
 	}
 
+	// Лог относится к дню своего начала; end_date включает весь этот день
+	query := config.DB.Where("user_id = ? AND start_time >= ? AND start_time < ?", userID, startDate.UTC(), endDate.AddDate(0, 0, 1).UTC())
+
+	if projectIDStr := c.Query("project_id"); projectIDStr != "" {
+		projectID, err := strconv.Atoi(projectIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid project_id format"})
+			return
+		}
+		query = query.Where("task_id IN (?)", config.DB.Model(&models.Task{}).Select("id").Where("project_id = ?", projectID))
+	}
+
+	if clientIDStr := c.Query("client_id"); clientIDStr != "" {
+		clientID, err := strconv.Atoi(clientIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid client_id format"})
+			return
+		}
+		projects := config.DB.Model(&models.Project{}).Select("id").Where("client_id = ?", clientID)
+		query = query.Where("task_id IN (?)", config.DB.Model(&models.Task{}).Select("id").Where("project_id IN (?)", projects))
+	}
+
+	if approvedStr := c.Query("approved"); approvedStr != "" {
+		approved, err := strconv.ParseBool(approvedStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid approved format"})
+			return
+		}
+		if approved {
+			query = approvedTime(query)
+		}
+	}
+
 	var taskLogs []models.TaskLog
-	result := config.DB.Where("user_id = ? AND start_time >= ? AND end_time <= ?", userID, startDate, endDate).Find(&taskLogs)
+	result := query.Find(&taskLogs)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
 		return
 	}
 
-	taskTimeMap := make(map[uint]*models.TaskTime)
+	tasks := make(map[uint]models.Task)
+	if len(taskLogs) > 0 {
+		taskIDs := make(map[uint]int)
+		for _, log := range taskLogs {
+			taskIDs[log.TaskID] = 0
+		}
+
+		var found []models.Task
+		if err := config.DB.Find(&found, mapKeys(taskIDs)).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		for _, task := range found {
+			tasks[task.ID] = task
+		}
+	}
+
+	logMinutes, err := roundTaskLogs(taskLogs, tasks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	logCosts, err := priceTaskLogs(taskLogs, tasks, logMinutes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 
+	if groupBy == "tag" {
+		groupTimes, err := rollUpTagMinutes(taskLogs, logMinutes, logCosts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, groupTimes)
+		return
+	}
+
+	// Minutes spent per task, each log is floored to whole minutes
+	taskMinutes := make(map[uint]int)
+	taskRounded := make(map[uint]int)
+	taskCosts := make(map[uint]costs)
 	for _, log := range taskLogs {
 		if log.EndTime.IsZero() {
 			continue
 		}
-		duration := log.EndTime.Sub(log.StartTime)
-		hours := int(duration.Hours())
-		minutes := int(duration.Minutes()) % 60
-
-		if taskTime, exists := taskTimeMap[log.TaskID]; exists {
-			taskTime.Hours += hours
-			taskTime.Minutes += minutes
-		} else {
-			var task models.Task
-			config.DB.First(&task, log.TaskID)
-			taskTimeMap[log.TaskID] = &models.TaskTime{
-				TaskID:  log.TaskID,
-				Title:   task.Title,
-				Hours:   hours,
-				Minutes: minutes,
+		taskMinutes[log.TaskID] += int(log.EndTime.Sub(log.StartTime).Minutes())
+		taskRounded[log.TaskID] += logMinutes[log.ID]
+		if cost, ok := logCosts[log.ID]; ok {
+			if err := addCost(taskCosts, log.TaskID, cost); err != nil {
+				c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+				return
 			}
 		}
 	}
 
-	taskTimes := make([]models.TaskTime, 0, len(taskTimeMap))
-	for _, taskTime := range taskTimeMap {
-		// Convert minutes to hours if minutes >= 60
-		taskTime.Hours += taskTime.Minutes / 60
-		taskTime.Minutes = taskTime.Minutes % 60
-		taskTimes = append(taskTimes, *taskTime)
+	if groupBy == "task" {
+		if err := loadAncestors(tasks); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+
+		// A parent's total includes the time and cost of all its subtasks
+		totalMinutes := make(map[uint]int)
+		totalRounded := make(map[uint]int)
+		totalCosts := make(map[uint]costs)
+		for taskID, minutes := range taskMinutes {
+			for _, id := range append([]uint{taskID}, ancestorIDs(taskID, tasks)...) {
+				totalMinutes[id] += minutes
+				totalRounded[id] += taskRounded[taskID]
+				if err := mergeCosts(totalCosts, id, taskCosts[taskID]); err != nil {
+					c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+					return
+				}
+			}
+		}
+
+		taskTimes := make([]models.TaskTime, 0, len(totalMinutes))
+		for taskID, total := range totalMinutes {
+			minutes, rounded, roundedTotal := taskMinutes[taskID], taskRounded[taskID], totalRounded[taskID]
+			taskTimes = append(taskTimes, models.TaskTime{
+				TaskID:              taskID,
+				ParentID:            tasks[taskID].ParentID,
+				Title:               tasks[taskID].Title,
+				Hours:               minutes / 60,
+				Minutes:             minutes % 60,
+				TotalHours:          total / 60,
+				TotalMinutes:        total % 60,
+				RoundedHours:        rounded / 60,
+				RoundedMinutes:      rounded % 60,
+				RoundedTotalHours:   roundedTotal / 60,
+				RoundedTotalMinutes: roundedTotal % 60,
+				Cost:                taskCosts[taskID].list(),
+				TotalCost:           totalCosts[taskID].list(),
+			})
+		}
+
+		// Sort taskTimes by total hours and minutes in descending order
+		sort.Slice(taskTimes, func(i, j int) bool {
+			if taskTimes[i].TotalHours == taskTimes[j].TotalHours {
+				return taskTimes[i].TotalMinutes > taskTimes[j].TotalMinutes
+			}
+			return taskTimes[i].TotalHours > taskTimes[j].TotalHours
+		})
+
+		c.JSON(http.StatusOK, taskTimes)
+		return
 	}
 
-	// Sort taskTimes by hours and minutes in descending order
-	sort.Slice(taskTimes, func(i, j int) bool {
-		if taskTimes[i].Hours == taskTimes[j].Hours {
-			return taskTimes[i].Minutes > taskTimes[j].Minutes
+	groupTimes, err := rollUpTaskMinutes(taskMinutes, taskRounded, taskCosts, tasks, groupBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, groupTimes)
+}
+
+// Догрузка в tasks недостающих предков, чтобы родитель попал в отчёт,
+// даже если по нему самому времени не списано
+func loadAncestors(tasks map[uint]models.Task) error {
+	for {
+		missing := make(map[uint]int)
+		for _, task := range tasks {
+			if task.ParentID == nil {
+				continue
+			}
+			if _, ok := tasks[*task.ParentID]; !ok {
+				missing[*task.ParentID] = 0
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+
+		var parents []models.Task
+		if err := config.DB.Find(&parents, mapKeys(missing)).Error; err != nil {
+			return err
 		}
-		return taskTimes[i].Hours > taskTimes[j].Hours
+		if len(parents) == 0 {
+			return nil
+		}
+		for _, parent := range parents {
+			tasks[parent.ID] = parent
+		}
+	}
+}
+
+// Цепочка родителей задачи снизу вверх
+func ancestorIDs(taskID uint, tasks map[uint]models.Task) []uint {
+	var ids []uint
+
+	// seen guards against a broken hierarchy looping forever
+	seen := map[uint]bool{taskID: true}
+	for parentID := tasks[taskID].ParentID; parentID != nil && !seen[*parentID]; parentID = tasks[*parentID].ParentID {
+		if _, ok := tasks[*parentID]; !ok {
+			break
+		}
+		seen[*parentID] = true
+		ids = append(ids, *parentID)
+	}
+
+	return ids
+}
+
+// Суммирование времени задач по проектам или клиентам
+func rollUpTaskMinutes(taskMinutes, taskRounded map[uint]int, taskCosts map[uint]costs, tasks map[uint]models.Task, groupBy string) ([]models.GroupTime, error) {
+	projectMinutes := make(map[uint]int)
+	projectRounded := make(map[uint]int)
+	projectCosts := make(map[uint]costs)
+	for taskID, minutes := range taskMinutes {
+		var projectID uint
+		if task, ok := tasks[taskID]; ok && task.ProjectID != nil {
+			projectID = *task.ProjectID
+		}
+		projectMinutes[projectID] += minutes
+		projectRounded[projectID] += taskRounded[taskID]
+		if err := mergeCosts(projectCosts, projectID, taskCosts[taskID]); err != nil {
+			return nil, err
+		}
+	}
+
+	var projects []models.Project
+	if err := config.DB.Where("id IN ?", mapKeys(projectMinutes)).Find(&projects).Error; err != nil {
+		return nil, err
+	}
+
+	groupMinutes := make(map[uint]int)
+	groupRounded := make(map[uint]int)
+	groupCosts := make(map[uint]costs)
+	names := make(map[uint]string)
+
+	if groupBy == "project" {
+		for _, project := range projects {
+			names[project.ID] = project.Name
+		}
+		groupMinutes = projectMinutes
+		groupRounded = projectRounded
+		groupCosts = projectCosts
+	} else {
+		clientIDs := make(map[uint]uint)
+		for _, project := range projects {
+			clientIDs[project.ID] = project.ClientID
+		}
+		for projectID, minutes := range projectMinutes {
+			groupMinutes[clientIDs[projectID]] += minutes
+			groupRounded[clientIDs[projectID]] += projectRounded[projectID]
+			if err := mergeCosts(groupCosts, clientIDs[projectID], projectCosts[projectID]); err != nil {
+				return nil, err
+			}
+		}
+
+		var clients []models.Client
+		if err := config.DB.Where("id IN ?", mapKeys(groupMinutes)).Find(&clients).Error; err != nil {
+			return nil, err
+		}
+		for _, client := range clients {
+			names[client.ID] = client.Name
+		}
+	}
+
+	return groupTimes(groupMinutes, groupRounded, groupCosts, names), nil
+}
+
+// Суммирование времени по тегам лога и его задачи
+func rollUpTagMinutes(taskLogs []models.TaskLog, logMinutes map[uint]int, logCosts map[uint]models.Cost) ([]models.GroupTime, error) {
+	logIDs := make([]uint, 0, len(taskLogs))
+	taskIDs := make(map[uint]int)
+	for _, log := range taskLogs {
+		logIDs = append(logIDs, log.ID)
+		taskIDs[log.TaskID] = 0
+	}
+
+	logLinks, err := loadTagLinks("task_log_tags", "task_log_id", logIDs)
+	if err != nil {
+		return nil, err
+	}
+	taskLinks, err := loadTagLinks("task_tags", "task_id", mapKeys(taskIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[uint]string)
+	logTags := make(map[uint][]uint)
+	taskTags := make(map[uint][]uint)
+	for _, link := range logLinks {
+		names[link.TagID] = link.Name
+		logTags[link.OwnerID] = append(logTags[link.OwnerID], link.TagID)
+	}
+	for _, link := range taskLinks {
+		names[link.TagID] = link.Name
+		taskTags[link.OwnerID] = append(taskTags[link.OwnerID], link.TagID)
+	}
+
+	groupMinutes := make(map[uint]int)
+	groupRounded := make(map[uint]int)
+	groupCosts := make(map[uint]costs)
+	for _, log := range taskLogs {
+		if log.EndTime.IsZero() {
+			continue
+		}
+		minutes := int(log.EndTime.Sub(log.StartTime).Minutes())
+
+		tags := make(map[uint]bool)
+		for _, tagID := range logTags[log.ID] {
+			tags[tagID] = true
+		}
+		for _, tagID := range taskTags[log.TaskID] {
+			tags[tagID] = true
+		}
+
+		if len(tags) == 0 {
+			tags[0] = true
+		}
+		for tagID := range tags {
+			groupMinutes[tagID] += minutes
+			groupRounded[tagID] += logMinutes[log.ID]
+			if cost, ok := logCosts[log.ID]; ok {
+				if err := addCost(groupCosts, tagID, cost); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return groupTimes(groupMinutes, groupRounded, groupCosts, names), nil
+}
+
+// Перевод минут по группам в часы и минуты с сортировкой по убыванию
+func groupTimes(groupMinutes, groupRounded map[uint]int, groupCosts map[uint]costs, names map[uint]string) []models.GroupTime {
+	times := make([]models.GroupTime, 0, len(groupMinutes))
+	for id, minutes := range groupMinutes {
+		times = append(times, models.GroupTime{
+			ID:             id,
+			Name:           names[id],
+			Hours:          minutes / 60,
+			Minutes:        minutes % 60,
+			RoundedHours:   groupRounded[id] / 60,
+			RoundedMinutes: groupRounded[id] % 60,
+			Cost:           groupCosts[id].list(),
+		})
+	}
+
+	sort.Slice(times, func(i, j int) bool {
+		if times[i].Hours == times[j].Hours {
+			return times[i].Minutes > times[j].Minutes
+		}
+		return times[i].Hours > times[j].Hours
 	})
 
-	c.JSON(http.StatusOK, taskTimes)
+	return times
+}
+
+func mapKeys(m map[uint]int) []uint {
+	keys := make([]uint, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
 }
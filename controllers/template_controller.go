@@ -0,0 +1,419 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"em-test/query"
+	"em-test/recurrence"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ошибка для отката транзакции, если срабатывание уже создано другим запуском
+var errOccurrenceExists = errors.New("occurrence already materialized")
+
+// Поля шаблона задачи, доступные для фильтрации и сортировки
+var templateListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Type: query.Int},
+		"project_id": {Column: "project_id", Type: query.Int},
+		"title":      {Column: "title", Type: query.String, DefaultOp: query.Contains},
+		"paused":     {Column: "paused", Type: query.Bool},
+		"created_at": {Column: "created_at", Type: query.Time},
+		"updated_at": {Column: "updated_at", Type: query.Time},
+	},
+}
+
+// Получение списка шаблонов задач
+// @Summary Get all task templates
+// @Description Get a paginated list of recurring task templates. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param project_id query int false "Project ID"
+// @Param paused query bool false "Paused"
+// @Param title query string false "Title contains"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. title)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.TaskTemplate]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /templates [get]
+func GetTemplatesHandler(c *gin.Context) {
+	params, err := query.Parse(c.Request.URL.Query(), templateListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var templates []models.TaskTemplate
+	total, err := params.Find(config.DB.Model(&models.TaskTemplate{}), &templates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(templates, total, params, c.Request.URL))
+}
+
+// Получение шаблона задачи по id
+// @Summary Get task template by ID
+// @Description Get a single recurring task template by its ID
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path int true "Template ID"
+// @Param If-None-Match header string false "ETag of a cached version"
+// @Success 200 {object} models.TaskTemplate
+// @Header 200 {string} ETag "Resource version"
+// @Success 304
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /templates/{id} [get]
+func GetTemplateHandler(c *gin.Context) {
+	id := c.Param("id")
+	var template models.TaskTemplate
+
+	result := config.DB.First(&template, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Template not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if notModified(c, template.Version) {
+		return
+	}
+
+	setETag(c, template.Version)
+	c.JSON(http.StatusOK, template)
+}
+
+// Создание шаблона задачи
+// @Summary Create a task template
+// @Description Create a recurring task template. Recurrence is a cron expression in UTC ("minute hour day month weekday" or @hourly, @daily, @weekly, @monthly, @yearly); tasks are created for occurrences after the template is created.
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param template body models.TaskTemplate true "Template JSON"
+// @Success 201 {object} models.TaskTemplate
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /templates [post]
+func CreateTemplateHandler(c *gin.Context, validate *validator.Validate) {
+	var template models.TaskTemplate
+
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&template); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if template.ProjectID != nil && !recordExists(c, &models.Project{}, *template.ProjectID, "Project not found") {
+		return
+	}
+
+	template.Tags = normalizeTags(template.Tags)
+	template.MaterializedUntil = time.Now().UTC()
+	template.Version = 1
+
+	result := config.DB.Create(&template)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+
+	setETag(c, template.Version)
+	c.JSON(http.StatusCreated, template)
+}
+
+// Изменение шаблона задачи
+// @Summary Update a task template
+// @Description Update a recurring task template by ID. A changed recurrence or resuming a paused template applies from now on, missed occurrences are not created.
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path int true "Template ID"
+// @Param template body models.TaskTemplate true "Template data"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.TaskTemplate
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /templates/{id} [put]
+func UpdateTemplateHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var template models.TaskTemplate
+
+	result := config.DB.First(&template, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Template not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, template.Version) {
+		return
+	}
+
+	templateID, version, createdAt := template.ID, template.Version, template.CreatedAt
+	schedule, paused, materializedUntil := template.Recurrence, template.Paused, template.MaterializedUntil
+
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&template); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if template.ProjectID != nil && !recordExists(c, &models.Project{}, *template.ProjectID, "Project not found") {
+		return
+	}
+
+	template.ID = templateID
+	template.CreatedAt = createdAt
+	template.Tags = normalizeTags(template.Tags)
+	template.MaterializedUntil = materializedUntil
+	if template.Recurrence != schedule || (paused && !template.Paused) {
+		template.MaterializedUntil = time.Now().UTC()
+	}
+	template.Version = version + 1
+
+	result = config.DB.Select("*").Where("version = ?", version).Save(&template)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	setETag(c, template.Version)
+	c.JSON(http.StatusOK, template)
+}
+
+// Удаление шаблона задачи
+// @Summary Delete a task template
+// @Description Delete a recurring task template by ID. Tasks already created from it are kept.
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path int true "Template ID"
+// @Param If-Match header string false "Expected ETag"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /templates/{id} [delete]
+func DeleteTemplateHandler(c *gin.Context) {
+	id := c.Param("id")
+	var template models.TaskTemplate
+
+	result := config.DB.First(&template, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Template not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, template.Version) {
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("version = ?", template.Version).Delete(&template)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVersionConflict
+		}
+
+		return tx.Where("template_id = ?", template.ID).Delete(&models.TaskOccurrence{}).Error
+	})
+	if err == errVersionConflict {
+		versionConflict(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Предпросмотр ближайших срабатываний шаблона
+// @Summary Preview template occurrences
+// @Description Get the upcoming times at which tasks will be created from the template
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path int true "Template ID"
+// @Param from query string false "Start of the preview (RFC3339 or YYYY-MM-DD), defaults to now"
+// @Param count query int false "Number of occurrences (default 10, max 100)"
+// @Success 200 {array} string
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /templates/{id}/occurrences [get]
+func GetTemplateOccurrencesHandler(c *gin.Context) {
+	id := c.Param("id")
+	var template models.TaskTemplate
+
+	result := config.DB.First(&template, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Template not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	from := time.Now().UTC()
+	if fromStr := c.Query("from"); fromStr != "" {
+		parsed, err := query.ParseTime(fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid from format"})
+			return
+		}
+		from = parsed.UTC()
+	}
+
+	count := 10
+	if countStr := c.Query("count"); countStr != "" {
+		parsed, err := strconv.Atoi(countStr)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid count"})
+			return
+		}
+		count = min(parsed, 100)
+	}
+
+	rule, err := recurrence.Parse(template.Recurrence)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule.Upcoming(from, count))
+}
+
+// Создание задач по всем активным шаблонам, срабатывания которых наступили к now.
+// Вызывается планировщиком; повторный запуск не создаёт дубликатов.
+func MaterializeTaskTemplates(now time.Time) error {
+	var templates []models.TaskTemplate
+	if err := config.DB.Where("paused = ?", false).Find(&templates).Error; err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, template := range templates {
+		if err := materializeTemplate(template, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Создание задачи по последнему наступившему срабатыванию шаблона. Срабатывания,
+// пропущенные за время простоя, задним числом не создаются.
+func materializeTemplate(template models.TaskTemplate, now time.Time) error {
+	rule, err := recurrence.Parse(template.Recurrence)
+	if err != nil {
+		return err
+	}
+
+	var latest time.Time
+	for at := rule.Next(template.MaterializedUntil.UTC()); !at.IsZero() && !at.After(now); at = rule.Next(at) {
+		latest = at
+	}
+	if latest.IsZero() {
+		return nil
+	}
+
+	if err := createOccurrence(template, latest); err != nil {
+		return err
+	}
+
+	// Условие не даёт откатить отметку, сдвинутую изменением шаблона
+	return config.DB.Model(&models.TaskTemplate{}).
+		Where("id = ? AND materialized_until < ?", template.ID, latest).
+		UpdateColumn("materialized_until", latest).Error
+}
+
+func createOccurrence(template models.TaskTemplate, at time.Time) error {
+	task := models.Task{
+		ProjectID:       template.ProjectID,
+		Title:           template.Title,
+		Description:     template.Description,
+		Status:          config.TaskWorkflow.Initial,
+		EstimateMinutes: template.EstimateMinutes,
+		Tags:            template.Tags,
+		Version:         1,
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+
+		occurrence := models.TaskOccurrence{TemplateID: template.ID, OccurrenceAt: at, TaskID: task.ID}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&occurrence)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errOccurrenceExists
+		}
+
+		if err := setTaskTags(tx, task.ID, task.Tags); err != nil {
+			return err
+		}
+		return recordTaskEvent(tx, models.EventTaskCreated, task)
+	})
+	if err == errOccurrenceExists {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"testing"
+	"time"
+)
+
+// После простоя создаётся задача только по последнему наступившему срабатыванию
+func TestMaterializeTemplateSkipsMissedOccurrences(t *testing.T) {
+	setupTestDB(t, &models.TaskTemplate{}, &models.TaskOccurrence{}, &models.Task{}, &models.Tag{}, &models.TaskTag{}, &models.OutboxEvent{})
+
+	monday := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	template := models.TaskTemplate{Title: "Weekly sync", Description: "Sync", Recurrence: "0 9 * * 1", MaterializedUntil: monday, Version: 1}
+	if err := config.DB.Create(&template).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	occurrences := func() []models.TaskOccurrence {
+		var rows []models.TaskOccurrence
+		if err := config.DB.Order("occurrence_at").Find(&rows).Error; err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+
+	// Срабатывание ещё не наступило
+	if err := MaterializeTaskTemplates(monday.Add(8 * time.Hour)); err != nil {
+		t.Fatalf("MaterializeTaskTemplates: %v", err)
+	}
+	if rows := occurrences(); len(rows) != 0 {
+		t.Fatalf("created %d occurrences before the first one was due", len(rows))
+	}
+
+	// Наступили четыре срабатывания, задача создаётся только по последнему; повторный запуск её не дублирует
+	now := monday.AddDate(0, 0, 22)
+	for i := 0; i < 2; i++ {
+		if err := MaterializeTaskTemplates(now); err != nil {
+			t.Fatalf("MaterializeTaskTemplates: %v", err)
+		}
+	}
+	rows := occurrences()
+	if want := monday.AddDate(0, 0, 21).Add(9 * time.Hour); len(rows) != 1 || !rows[0].OccurrenceAt.Equal(want) {
+		t.Fatalf("occurrences = %v, want one at %v", rows, want)
+	}
+
+	var tasks int64
+	config.DB.Model(&models.Task{}).Count(&tasks)
+	if tasks != 1 {
+		t.Errorf("created %d tasks, want 1", tasks)
+	}
+
+	config.DB.First(&template, template.ID)
+	if !template.MaterializedUntil.Equal(rows[0].OccurrenceAt) {
+		t.Errorf("materialized_until = %v, want %v", template.MaterializedUntil, rows[0].OccurrenceAt)
+	}
+}
@@ -0,0 +1,443 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"em-test/query"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// Поля табеля, доступные для фильтрации и сортировки
+var timesheetListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":          {Column: "id", Type: query.Int},
+		"user_id":     {Column: "user_id", Type: query.Int},
+		"week_start":  {Column: "week_start", Type: query.Time},
+		"status":      {Column: "status", Type: query.String},
+		"reviewer_id": {Column: "reviewer_id", Type: query.Int},
+		"created_at":  {Column: "created_at", Type: query.Time},
+	},
+	DefaultSort: "-week_start",
+}
+
+// Получение списка табелей
+// @Summary Get all timesheets
+// @Description Get a paginated list of weekly timesheets. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
+// @Tags timesheets
+// @Accept json
+// @Produce json
+// @Param user_id query int false "User ID"
+// @Param status query string false "Status" Enums(draft, submitted, approved, rejected)
+// @Param week_start query string false "Week start (RFC 3339)"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -week_start)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.Timesheet]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /timesheets [get]
+func GetTimesheetsHandler(c *gin.Context) {
+	params, err := query.Parse(c.Request.URL.Query(), timesheetListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var timesheets []models.Timesheet
+	total, err := params.Find(config.DB.Model(&models.Timesheet{}), &timesheets)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(timesheets, total, params, c.Request.URL))
+}
+
+// Получение табеля по id
+// @Summary Get timesheet by ID
+// @Description Get a timesheet with the task logs started during its week and their total time
+// @Tags timesheets
+// @Accept json
+// @Produce json
+// @Param id path int true "Timesheet ID"
+// @Success 200 {object} models.Timesheet
+// @Header 200 {string} ETag "Resource version"
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /timesheets/{id} [get]
+func GetTimesheetHandler(c *gin.Context) {
+	id := c.Param("id")
+	var timesheet models.Timesheet
+
+	result := config.DB.First(&timesheet, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Timesheet not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	var taskLogs []models.TaskLog
+	result = config.DB.Where("user_id = ? AND start_time >= ? AND start_time < ?", timesheet.UserID, timesheet.WeekStart, timesheet.WeekEnd).
+		Order("start_time, id").Find(&taskLogs)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+
+	if err := attachTaskLogTags(taskLogs); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	totalMinutes := 0
+	for _, log := range taskLogs {
+		if !log.EndTime.IsZero() {
+			totalMinutes += int(log.EndTime.Sub(log.StartTime).Minutes())
+		}
+	}
+
+	timesheet.TaskLogs = taskLogs
+	timesheet.TotalMinutes = &totalMinutes
+
+	setETag(c, timesheet.Version)
+	c.JSON(http.StatusOK, timesheet)
+}
+
+// Создание табеля
+// @Summary Create a timesheet
+// @Description Create a draft timesheet for the user's week. week_start must be a Monday; the week covers task logs started from Monday 00:00 in the user's time zone until the next Monday.
+// @Tags timesheets
+// @Accept json
+// @Produce json
+// @Param timesheet body models.TimesheetRequest true "Timesheet JSON"
+// @Success 201 {object} models.Timesheet
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /timesheets [post]
+func CreateTimesheetHandler(c *gin.Context, validate *validator.Validate) {
+	var request models.TimesheetRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&request); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, request.UserID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+
+	weekStart, _ := time.ParseInLocation("2006-01-02", request.WeekStart, userLocation(user))
+	if weekStart.Weekday() != time.Monday {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "week_start must be a Monday"})
+		return
+	}
+
+	var existing int64
+	weekEnd := weekStart.AddDate(0, 0, 7)
+	err := config.DB.Model(&models.Timesheet{}).
+		Where("user_id = ? AND week_start < ? AND week_end > ?", request.UserID, weekEnd.UTC(), weekStart.UTC()).
+		Count(&existing).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Timesheet for this week already exists"})
+		return
+	}
+
+	timesheet := models.Timesheet{
+		UserID:    request.UserID,
+		WeekStart: weekStart.UTC(),
+		WeekEnd:   weekEnd.UTC(),
+		Status:    models.TimesheetDraft,
+		Version:   1,
+	}
+
+	if err := config.DB.Create(&timesheet).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, timesheet.Version)
+	c.JSON(http.StatusCreated, timesheet)
+}
+
+// Отправка табеля на утверждение
+// @Summary Submit a timesheet
+// @Description Submit a draft or rejected timesheet for approval by the user's manager. Only the owner can submit, and not while a timer of the week is still running.
+// @Description Task logs of a submitted timesheet cannot be changed.
+// @Tags timesheets
+// @Accept json
+// @Produce json
+// @Param id path int true "Timesheet ID"
+// @Param submit body models.TimesheetSubmitRequest true "Submitting user"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.Timesheet
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /timesheets/{id}/submit [post]
+func SubmitTimesheetHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var timesheet models.Timesheet
+
+	result := config.DB.First(&timesheet, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Timesheet not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, timesheet.Version) {
+		return
+	}
+
+	var request models.TimesheetSubmitRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&request); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if request.UserID != timesheet.UserID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Only the owner can submit the timesheet"})
+		return
+	}
+
+	if timesheet.Status != models.TimesheetDraft && timesheet.Status != models.TimesheetRejected {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Timesheet is " + timesheet.Status})
+		return
+	}
+
+	var running int64
+	err := config.DB.Model(&models.TaskLog{}).
+		Where("user_id = ? AND start_time >= ? AND start_time < ? AND end_time = ?", timesheet.UserID, timesheet.WeekStart, timesheet.WeekEnd, time.Time{}).
+		Count(&running).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if running > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Timesheet has running timers"})
+		return
+	}
+
+	now := time.Now()
+	timesheet.Status = models.TimesheetSubmitted
+	timesheet.SubmittedAt = &now
+	timesheet.RejectionReason = ""
+	timesheet.ReviewerID = nil
+	timesheet.ReviewedAt = nil
+
+	saveTimesheet(c, timesheet)
+}
+
+// Утверждение табеля
+// @Summary Approve a timesheet
+// @Description Approve a submitted timesheet. Only the manager of the timesheet's user can approve it; the week becomes read-only.
+// @Description There is no authentication yet, so the reviewer is identified only by manager_id as sent by the client.
+// @Tags timesheets
+// @Accept json
+// @Produce json
+// @Param id path int true "Timesheet ID"
+// @Param review body models.TimesheetReviewRequest true "Reviewing manager"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.Timesheet
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /timesheets/{id}/approve [post]
+func ApproveTimesheetHandler(c *gin.Context, validate *validator.Validate) {
+	reviewTimesheet(c, validate, models.TimesheetApproved)
+}
+
+// Отклонение табеля
+// @Summary Reject a timesheet
+// @Description Reject a submitted timesheet with a reason. Only the manager of the timesheet's user can reject it; the user can then correct the week and submit it again.
+// @Description There is no authentication yet, so the reviewer is identified only by manager_id as sent by the client.
+// @Tags timesheets
+// @Accept json
+// @Produce json
+// @Param id path int true "Timesheet ID"
+// @Param review body models.TimesheetReviewRequest true "Reviewing manager and reason"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.Timesheet
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /timesheets/{id}/reject [post]
+func RejectTimesheetHandler(c *gin.Context, validate *validator.Validate) {
+	reviewTimesheet(c, validate, models.TimesheetRejected)
+}
+
+func reviewTimesheet(c *gin.Context, validate *validator.Validate, status string) {
+	id := c.Param("id")
+	var timesheet models.Timesheet
+
+	result := config.DB.First(&timesheet, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Timesheet not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, timesheet.Version) {
+		return
+	}
+
+	var request models.TimesheetReviewRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&request); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if status == models.TimesheetRejected && request.Reason == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Rejection reason is required"})
+		return
+	}
+
+	if request.ManagerID == timesheet.UserID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Users cannot review their own timesheet"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, timesheet.UserID).Error; err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if user.ManagerID == nil || *user.ManagerID != request.ManagerID {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: "Only the user's manager can review the timesheet"})
+		return
+	}
+
+	if timesheet.Status != models.TimesheetSubmitted {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Timesheet is " + timesheet.Status})
+		return
+	}
+
+	now := time.Now()
+	timesheet.Status = status
+	timesheet.ReviewerID = &request.ManagerID
+	timesheet.ReviewedAt = &now
+	if status == models.TimesheetRejected {
+		timesheet.RejectionReason = request.Reason
+	}
+
+	saveTimesheet(c, timesheet)
+}
+
+func saveTimesheet(c *gin.Context, timesheet models.Timesheet) {
+	version := timesheet.Version
+	timesheet.Version = version + 1
+
+	result := config.DB.Select("*").Where("version = ?", version).Save(&timesheet)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	setETag(c, timesheet.Version)
+	c.JSON(http.StatusOK, timesheet)
+}
+
+// Ответ 409, если момент at попадает в отправленный или утверждённый табель пользователя
+func timesheetLocked(c *gin.Context, userID uint, at time.Time) bool {
+	timesheet, locked, err := lockingTimesheetAt(userID, at)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return true
+	}
+	if !locked {
+		return false
+	}
+
+	c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Timesheet for this week is " + timesheet.Status})
+	return true
+}
+
+// Отправленный или утверждённый табель пользователя, в неделю которого попадает at
+func lockingTimesheetAt(userID uint, at time.Time) (models.Timesheet, bool, error) {
+	var timesheet models.Timesheet
+
+	result := config.DB.Where("user_id = ? AND week_start <= ? AND week_end > ? AND status IN ?",
+		userID, at, at, []string{models.TimesheetSubmitted, models.TimesheetApproved}).Limit(1).Find(&timesheet)
+	return timesheet, result.RowsAffected > 0, result.Error
+}
+
+// Только логи, попадающие в утверждённые табели
+func approvedTime(db *gorm.DB) *gorm.DB {
+	return db.Where("EXISTS (?)", config.DB.Model(&models.Timesheet{}).Select("1").
+		Where("timesheets.user_id = task_logs.user_id AND timesheets.status = ?", models.TimesheetApproved).
+		Where("task_logs.start_time >= timesheets.week_start AND task_logs.start_time < timesheets.week_end"))
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Часовой пояс пользователя; пустой или неизвестный пояс считается UTC
+func userLocation(user models.User) *time.Location {
+	if user.TimeZone == "" {
+		return time.UTC
+	}
+
+	loc, err := time.LoadLocation(user.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Часовой пояс, в котором считаются границы дней отчёта: параметр tz,
+// иначе пояс пользователя userID (0 — без пользователя), иначе UTC. При неверном tz отвечает 400.
+func reportLocation(c *gin.Context, userID uint) (*time.Location, bool) {
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil || strings.EqualFold(tz, "local") {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid tz"})
+			return nil, false
+		}
+		return loc, true
+	}
+
+	if userID == 0 {
+		return time.UTC, true
+	}
+
+	var user models.User
+	if err := config.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return time.UTC, true
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return nil, false
+	}
+
+	return userLocation(user), true
+}
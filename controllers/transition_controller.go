@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"em-test/config"
+	"em-test/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// Получение процесса статусов задач
+// @Summary Get task workflow
+// @Description Get task statuses and allowed transitions between them
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Success 200 {object} workflow.Workflow
+// @Router /tasks/workflow [get]
+func GetWorkflowHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, config.TaskWorkflow)
+}
+
+// Смена статуса задачи
+// @Summary Change task status
+// @Description Move a task to another status. Only transitions allowed by the workflow are accepted.
+// @Description A task cannot be moved to a terminal status while any of its blockers is open.
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param transition body models.TransitionRequest true "Transition JSON"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.Task
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/{id}/transitions [post]
+func TransitionTaskHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var task models.Task
+
+	result := config.DB.First(&task, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, task.Version) {
+		return
+	}
+
+	var request models.TransitionRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&request); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if !config.TaskWorkflow.IsState(request.To) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Unknown status: " + request.To})
+		return
+	}
+
+	if !config.TaskWorkflow.CanTransition(task.Status, request.To) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Transition from " + task.Status + " to " + request.To + " is not allowed"})
+		return
+	}
+
+	if config.TaskWorkflow.IsTerminal(request.To) {
+		blockers, err := openBlockers(task.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		if blockers > 0 {
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Task is blocked by open tasks"})
+			return
+		}
+	}
+
+	if request.UserID != nil && !recordExists(c, &models.User{}, *request.UserID, "User not found") {
+		return
+	}
+
+	transition := models.TaskTransition{
+		TaskID:     task.ID,
+		FromStatus: task.Status,
+		ToStatus:   request.To,
+		UserID:     request.UserID,
+		Comment:    request.Comment,
+	}
+
+	// Теги задачи меняются только со сменой версии, поэтому загруженные заранее
+	// теги попадут в событие актуальными
+	if err := loadTaskTags(&task); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	version := task.Version
+	task.Status = request.To
+	task.Version = version + 1
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Select("*").Where("version = ?", version).Save(&task)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVersionConflict
+		}
+
+		if err := tx.Create(&transition).Error; err != nil {
+			return err
+		}
+		return recordTaskEvent(tx, models.EventTaskUpdated, task)
+	})
+	if err == errVersionConflict {
+		versionConflict(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	setETag(c, task.Version)
+	c.JSON(http.StatusOK, task)
+}
+
+// Получение истории смены статусов задачи
+// @Summary Get task status history
+// @Description Get all status transitions of a task in chronological order
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {array} models.TaskTransition
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /tasks/{id}/transitions [get]
+func GetTaskTransitionsHandler(c *gin.Context) {
+	id := c.Param("id")
+	var task models.Task
+
+	result := config.DB.First(&task, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Task not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	var transitions []models.TaskTransition
+	result = config.DB.Where("task_id = ?", task.ID).Order("created_at, id").Find(&transitions)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transitions)
+}
@@ -3,68 +3,61 @@ package controllers
 import (
 	"em-test/config"
 	"em-test/models"
+	"em-test/query"
 	"net/http"
 
-	"strconv"
-
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
 )
 
+// Поля пользователя, доступные для фильтрации и сортировки
+var userListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":              {Column: "id", Type: query.Int},
+		"name":            {Column: "name", Type: query.String, DefaultOp: query.Contains},
+		"surname":         {Column: "surname", Type: query.String, DefaultOp: query.Contains},
+		"patronymic":      {Column: "patronymic", Type: query.String, DefaultOp: query.Contains},
+		"address":         {Column: "address", Type: query.String, DefaultOp: query.Contains},
+		"passport_number": {Column: "passport_number", Type: query.String},
+		"manager_id":      {Column: "manager_id", Type: query.Int},
+		"created_at":      {Column: "created_at", Type: query.Time},
+		"updated_at":      {Column: "updated_at", Type: query.Time},
+	},
+}
+
 // Получение списка всех пользователей
 // @Summary Get all users
-// @Description Get a list of all users
+// @Description Get a paginated list of users. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
 // @Tags users
 // @Accept json
 // @Produce json
-// @Success 200 {array} models.User
+// @Param name query string false "Name contains"
+// @Param surname query string false "Surname contains"
+// @Param address query string false "Address contains"
+// @Param manager_id query int false "Manager ID"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -created_at,surname)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.User]
+// @Failure 400 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /users [get]
 func GetUsersHandler(c *gin.Context) {
-    var users []models.User
-
-		name := c.Query("name")
-    surname := c.Query("surname")
-    address := c.Query("address")
-		pageStr := c.Query("page")
-		pageSizeStr := c.Query("page_size")
-
-		page := 1
-		pageSize := 10
-
-		if pageStr != "" {
-			if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-				page = p
-			}
-		}
-
-		if pageSizeStr != "" {
-			if ps, err := strconv.Atoi(pageSizeStr); err == nil && ps > 0 {
-				pageSize = ps
-			}
-		}
-
-		query := config.DB
-
-		if name != "" {
-        query = query.Where("name ILIKE ?", "%"+name+"%")
-    }
-    if surname != "" {
-        query = query.Where("surname ILIKE ?", "%"+surname+"%")
-    }
-    if address != "" {
-        query = query.Where("address ILIKE ?", "%"+address+"%")
-    }
+	params, err := query.Parse(c.Request.URL.Query(), userListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 
-		offset := (page - 1) * pageSize
-		result := query.Limit(pageSize).Offset(offset).Find(&users)
+	var users []models.User
+	total, err := params.Find(config.DB.Model(&models.User{}), &users)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 
-    if result.Error != nil {
-        c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
-        return
-    }
-    c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, query.NewPage(users, total, params, c.Request.URL))
 }
 
 // Получение пользователя по id
@@ -74,7 +67,10 @@ func GetUsersHandler(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param If-None-Match header string false "ETag of a cached version"
 // @Success 200 {object} models.User
+// @Header 200 {string} ETag "Resource version"
+// @Success 304
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /users/{id} [get]
@@ -90,8 +86,14 @@ func GetUserHandler(c *gin.Context) {
 		} else {
 			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
 		}
+		return
 	}
 
+	if notModified(c, user.Version) {
+		return
+	}
+
+	setETag(c, user.Version)
 	c.JSON(http.StatusOK, user)
 }
 
@@ -124,11 +126,25 @@ func CreateUserHandler(c *gin.Context, validate *validator.Validate) {
         return
     }
 
-    result := config.DB.Create(&user)
-    if result.Error != nil {
-        c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+    if user.ManagerID != nil && !recordExists(c, &models.User{}, *user.ManagerID, "Manager not found") {
+        return
+    }
+
+    if user.TimeZone == "" {
+        user.TimeZone = "UTC"
+    }
+    user.Version = 1
+    err := config.DB.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Create(&user).Error; err != nil {
+            return err
+        }
+        return recordUserEvent(tx, models.EventUserCreated, user)
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
         return
     }
+    setETag(c, user.Version)
     c.JSON(http.StatusCreated, user)
 }
 
@@ -139,9 +155,11 @@ func CreateUserHandler(c *gin.Context, validate *validator.Validate) {
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param If-Match header string false "Expected ETag"
 // @Success 204
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /users/{id} [delete]
 func DeleteUserHandler(c *gin.Context) {
@@ -158,9 +176,29 @@ func DeleteUserHandler(c *gin.Context) {
 		return
 	}
 
-	result = config.DB.Delete(&user)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+	if preconditionFailed(c, user.Version) {
+		return
+	}
+
+	// Подчинённые удаляемого пользователя остаются без руководителя
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("version = ?", user.Version).Delete(&user)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVersionConflict
+		}
+
+		return tx.Model(&models.User{}).Where("manager_id = ?", user.ID).
+			Updates(map[string]interface{}{"manager_id": nil, "version": gorm.Expr("version + 1")}).Error
+	})
+	if err == errVersionConflict {
+		versionConflict(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 
@@ -170,15 +208,18 @@ func DeleteUserHandler(c *gin.Context) {
 
 // Изменение данных пользователя
 // @Summary Update a user
-// @Description Update user details by ID
+// @Description Update user details by ID. A user cannot become a manager of their own manager, directly or through the chain of managers.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
 // @Param user body models.User true "User data"
+// @Param If-Match header string false "Expected ETag"
 // @Success 200 {object} models.User
+// @Header 200 {string} ETag "Resource version"
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /users/{id} [put]
 func UpdateUserHandler(c *gin.Context, validate *validator.Validate) {
@@ -195,6 +236,12 @@ func UpdateUserHandler(c *gin.Context, validate *validator.Validate) {
 		return
 	}
 
+	if preconditionFailed(c, user.Version) {
+		return
+	}
+
+	userID, version, createdAt := user.ID, user.Version, user.CreatedAt
+
 	if err := c.ShouldBindJSON(&user); err != nil {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
@@ -210,11 +257,66 @@ func UpdateUserHandler(c *gin.Context, validate *validator.Validate) {
 		return
 	}
 
-	result = config.DB.Save(&user)
+	if user.ManagerID != nil {
+		if *user.ManagerID == userID {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "User cannot be their own manager"})
+			return
+		}
+		if !recordExists(c, &models.User{}, *user.ManagerID, "Manager not found") {
+			return
+		}
+		if managerCycle(c, userID, *user.ManagerID) {
+			return
+		}
+	}
+
+	if user.TimeZone == "" {
+		user.TimeZone = "UTC"
+	}
+
+	user.ID = userID
+	user.CreatedAt = createdAt
+	user.Version = version + 1
+
+	result = config.DB.Select("*").Where("version = ?", version).Save(&user)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
 		return
 	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
 
+	setETag(c, user.Version)
 	c.JSON(http.StatusOK, user)
 }
+
+// Ответ 400, если руководитель managerID прямо или через своих руководителей
+// подчиняется userID: иначе пользователи могли бы утверждать собственные табели
+func managerCycle(c *gin.Context, userID, managerID uint) bool {
+	seen := make(map[uint]bool)
+
+	for id := managerID; !seen[id]; {
+		if id == userID {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Manager chain cannot contain a cycle"})
+			return true
+		}
+		seen[id] = true
+
+		var manager models.User
+		if err := config.DB.Select("id", "manager_id").First(&manager, id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return false
+			}
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return true
+		}
+		if manager.ManagerID == nil {
+			return false
+		}
+		id = *manager.ManagerID
+	}
+
+	return false
+}
@@ -0,0 +1,562 @@
+package controllers
+
+import (
+	"context"
+	"em-test/config"
+	"em-test/models"
+	"em-test/query"
+	"em-test/webhooks"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// Число попыток доставки, после которого доставка попадает в dead
+const webhookMaxAttempts = 8
+
+// Сколько доставок отправляется за один запуск планировщика
+const webhookBatchSize = 50
+
+// На это время доставка закрепляется за репликой, которая её отправляет
+const webhookLease = time.Minute
+
+// Поля вебхука, доступные для фильтрации и сортировки
+var webhookListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Type: query.Int},
+		"url":        {Column: "url", Type: query.String},
+		"active":     {Column: "active", Type: query.Bool},
+		"created_at": {Column: "created_at", Type: query.Time},
+	},
+	DefaultSort: "id",
+}
+
+// Поля доставки вебхука, доступные для фильтрации и сортировки
+var webhookDeliveryListSpec = query.Spec{
+	Fields: map[string]query.Field{
+		"id":              {Column: "id", Type: query.Int},
+		"webhook_id":      {Column: "webhook_id", Type: query.Int},
+		"event_type":      {Column: "event_type", Type: query.String},
+		"status":          {Column: "status", Type: query.String},
+		"attempts":        {Column: "attempts", Type: query.Int},
+		"next_attempt_at": {Column: "next_attempt_at", Type: query.Time},
+		"created_at":      {Column: "created_at", Type: query.Time},
+	},
+	DefaultSort: "-created_at",
+}
+
+// Получение списка вебхуков
+// @Summary Get all webhooks
+// @Description Get a paginated list of webhook subscriptions. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param active query bool false "Active"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -created_at)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.Webhook]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks [get]
+func GetWebhooksHandler(c *gin.Context) {
+	params, err := query.Parse(c.Request.URL.Query(), webhookListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var hooks []models.Webhook
+	total, err := params.Find(config.DB.Model(&models.Webhook{}), &hooks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(hooks, total, params, c.Request.URL))
+}
+
+// Получение вебхука по id
+// @Summary Get webhook by ID
+// @Description Get a single webhook subscription by its ID
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param If-None-Match header string false "ETag of a cached version"
+// @Success 200 {object} models.Webhook
+// @Header 200 {string} ETag "Resource version"
+// @Success 304
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks/{id} [get]
+func GetWebhookHandler(c *gin.Context) {
+	id := c.Param("id")
+	var hook models.Webhook
+
+	result := config.DB.First(&hook, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Webhook not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if notModified(c, hook.Version) {
+		return
+	}
+
+	setETag(c, hook.Version)
+	c.JSON(http.StatusOK, hook)
+}
+
+// Создание вебхука
+// @Summary Create a webhook
+// @Description Subscribe a URL to events. Each event is POSTed as a models.Event with X-Webhook-Event, X-Webhook-Delivery, X-Webhook-Timestamp and X-Webhook-Signature headers.
+// @Description The signature is sha256= followed by the hex HMAC-SHA256 of "<timestamp>.<body>" with the secret; a secret is generated when omitted.
+// @Description Deliveries answered with a non-2xx status are retried with exponential backoff from 30 seconds up to 6 hours; after 8 attempts they are dead.
+// @Description The secret is returned only in this response. URLs in private, loopback and link-local networks are rejected unless WEBHOOKS_ALLOW_PRIVATE_NETWORKS is set.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body models.WebhookWithSecret true "Webhook JSON"
+// @Success 201 {object} models.WebhookWithSecret
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks [post]
+func CreateWebhookHandler(c *gin.Context, validate *validator.Validate) {
+	var hook models.WebhookWithSecret
+
+	if err := c.ShouldBindJSON(&hook); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&hook); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if !webhookURLAllowed(c, hook.URL) {
+		return
+	}
+
+	if hook.Secret == "" {
+		secret, err := webhooks.NewSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		hook.Secret = secret
+	}
+	if hook.Active == nil {
+		active := true
+		hook.Active = &active
+	}
+
+	hook.Webhook.Secret = hook.Secret
+	hook.Version = 1
+	result := config.DB.Create(&hook.Webhook)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+
+	setETag(c, hook.Version)
+	c.JSON(http.StatusCreated, hook)
+}
+
+// Изменение вебхука
+// @Summary Update a webhook
+// @Description Update a webhook subscription by ID. The secret is kept when omitted and is not returned; deliveries of an inactive webhook wait until it is activated again.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param webhook body models.WebhookWithSecret true "Webhook data"
+// @Param If-Match header string false "Expected ETag"
+// @Success 200 {object} models.Webhook
+// @Header 200 {string} ETag "Resource version"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks/{id} [put]
+func UpdateWebhookHandler(c *gin.Context, validate *validator.Validate) {
+	id := c.Param("id")
+	var hook models.Webhook
+
+	result := config.DB.First(&hook, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Webhook not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, hook.Version) {
+		return
+	}
+
+	hookID, version, createdAt := hook.ID, hook.Version, hook.CreatedAt
+	input := models.WebhookWithSecret{Webhook: hook}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := validate.Struct(&input); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		errors := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			errors[i] = fieldError.Error()
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": errors})
+		return
+	}
+
+	if !webhookURLAllowed(c, input.URL) {
+		return
+	}
+
+	hook = input.Webhook
+	if input.Secret != "" {
+		hook.Secret = input.Secret
+	}
+
+	hook.ID = hookID
+	hook.CreatedAt = createdAt
+	hook.Version = version + 1
+
+	result = config.DB.Select("*").Where("version = ?", version).Save(&hook)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		versionConflict(c)
+		return
+	}
+
+	setETag(c, hook.Version)
+	c.JSON(http.StatusOK, hook)
+}
+
+// Удаление вебхука
+// @Summary Delete a webhook
+// @Description Delete a webhook subscription by ID together with its delivery log
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param If-Match header string false "Expected ETag"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks/{id} [delete]
+func DeleteWebhookHandler(c *gin.Context) {
+	id := c.Param("id")
+	var hook models.Webhook
+
+	result := config.DB.First(&hook, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Webhook not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if preconditionFailed(c, hook.Version) {
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("version = ?", hook.Version).Delete(&hook)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVersionConflict
+		}
+
+		return tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error
+	})
+	if err == errVersionConflict {
+		versionConflict(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Проверочная отправка вебхука
+// @Summary Test-fire a webhook
+// @Description Send a webhook.test event to the webhook right away and return the delivery with the response. Test deliveries are not retried.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 201 {object} models.WebhookDelivery
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhooks/{id}/test [post]
+func TestWebhookHandler(c *gin.Context) {
+	id := c.Param("id")
+	var hook models.Webhook
+
+	result := config.DB.First(&hook, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Webhook not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	now := time.Now().UTC()
+	event := models.Event{Type: models.EventWebhookTest, Time: now, Data: gin.H{"webhook_id": hook.ID, "url": hook.URL}}
+	delivery, err := newWebhookDelivery(hook, event, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := config.DB.Create(&delivery).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := attemptWebhookDelivery(c.Request.Context(), &delivery, hook, now, 1); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, delivery)
+}
+
+// Журнал доставок вебхуков
+// @Summary Get webhook deliveries
+// @Description Get a paginated delivery log. Filter with status=dead for the dead-letter list. Any field can be filtered as field=value or field[op]=value with op one of eq, ne, lt, gt, in, contains.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook_id query int false "Webhook ID"
+// @Param status query string false "Delivery status" Enums(pending, delivered, dead)
+// @Param event_type query string false "Event type"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -created_at)"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size (max 100)"
+// @Success 200 {object} models.Page[models.WebhookDelivery]
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhook-deliveries [get]
+func GetWebhookDeliveriesHandler(c *gin.Context) {
+	params, err := query.Parse(c.Request.URL.Query(), webhookDeliveryListSpec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var deliveries []models.WebhookDelivery
+	total, err := params.Find(config.DB.Model(&models.WebhookDelivery{}), &deliveries)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, query.NewPage(deliveries, total, params, c.Request.URL))
+}
+
+// Повторная отправка доставки из dead-letter списка
+// @Summary Retry a dead webhook delivery
+// @Description Put a dead delivery back into the queue with a fresh set of attempts
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Delivery ID"
+// @Success 200 {object} models.WebhookDelivery
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /webhook-deliveries/{id}/retry [post]
+func RetryWebhookDeliveryHandler(c *gin.Context) {
+	id := c.Param("id")
+	var delivery models.WebhookDelivery
+
+	result := config.DB.First(&delivery, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: "Delivery not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		}
+		return
+	}
+
+	if delivery.Status != models.DeliveryDead {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Only dead deliveries can be retried"})
+		return
+	}
+
+	delivery.Status = models.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now().UTC()
+	result = config.DB.Model(&delivery).Where("status = ?", models.DeliveryDead).
+		Select("status", "attempts", "next_attempt_at", "updated_at").Updates(&delivery)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: "Only dead deliveries can be retried"})
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// Ответ 400, если адрес вебхука ведёт во внутреннюю сеть
+func webhookURLAllowed(c *gin.Context, url string) bool {
+	if err := webhooks.CheckURL(url); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Webhook URL must not point to a private network"})
+		return false
+	}
+	return true
+}
+
+// Постановка события в очередь доставки активным вебхукам, подписанным на его тип
+func enqueueWebhookDeliveries(tx *gorm.DB, event models.Event) error {
+	var hooks []models.Webhook
+	if err := tx.Where("active = ?", true).Find(&hooks).Error; err != nil {
+		return err
+	}
+
+	var deliveries []models.WebhookDelivery
+	for _, hook := range hooks {
+		if !subscribed(hook, event.Type) {
+			continue
+		}
+		delivery, err := newWebhookDelivery(hook, event, event.Time)
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return tx.Create(&deliveries).Error
+}
+
+func subscribed(hook models.Webhook, eventType string) bool {
+	for _, t := range hook.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func newWebhookDelivery(hook models.Webhook, event models.Event, now time.Time) (models.WebhookDelivery, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	return models.WebhookDelivery{
+		WebhookID:     hook.ID,
+		EventID:       event.ID,
+		EventType:     event.Type,
+		Payload:       string(payload),
+		Status:        models.DeliveryPending,
+		NextAttemptAt: now,
+	}, nil
+}
+
+// Отправка доставок, время которых подошло. Вызывается планировщиком.
+// Доставка закрепляется условным обновлением next_attempt_at, поэтому при запуске
+// на нескольких репликах каждую попытку выполнит только одна из них.
+func DeliverWebhooks(now time.Time) error {
+	var deliveries []models.WebhookDelivery
+	err := config.DB.Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+		Where("webhook_id IN (?)", config.DB.Model(&models.Webhook{}).Select("id").Where("active = ?", true)).
+		Order("next_attempt_at").Limit(webhookBatchSize).Find(&deliveries).Error
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		result := config.DB.Model(&models.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", delivery.ID, models.DeliveryPending, delivery.NextAttemptAt).
+			Update("next_attempt_at", now.Add(webhookLease))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		var hook models.Webhook
+		if err := config.DB.First(&hook, delivery.WebhookID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				continue
+			}
+			return err
+		}
+
+		if err := attemptWebhookDelivery(context.Background(), &delivery, hook, now, webhookMaxAttempts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Одна попытка доставки с записью результата. После maxAttempts неудачных
+// попыток доставка становится dead, иначе следующая назначается с паузой.
+func attemptWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery, hook models.Webhook, now time.Time, maxAttempts int) error {
+	response, sendErr := webhooks.Send(ctx, hook.URL, hook.Secret, delivery.EventType, delivery.ID, []byte(delivery.Payload))
+
+	delivery.Attempts++
+	delivery.ResponseStatus = response.StatusCode
+	delivery.Response = response.Response
+	delivery.LastError = ""
+
+	switch {
+	case sendErr == nil:
+		delivered := time.Now().UTC()
+		delivery.Status = models.DeliveryDelivered
+		delivery.DeliveredAt = &delivered
+	case delivery.Attempts >= maxAttempts:
+		delivery.Status = models.DeliveryDead
+		delivery.LastError = sendErr.Error()
+	default:
+		delivery.NextAttemptAt = now.Add(webhooks.Backoff(delivery.Attempts))
+		delivery.LastError = sendErr.Error()
+	}
+
+	return config.DB.Select("status", "attempts", "next_attempt_at", "last_error", "response_status", "response", "delivered_at", "updated_at").
+		Save(delivery).Error
+}
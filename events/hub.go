@@ -0,0 +1,84 @@
+package events
+
+import (
+	"em-test/models"
+	"sync"
+)
+
+// Размер очереди подписчика; отстающий подписчик отключается и должен переподключиться
+const subscriberBuffer = 64
+
+// Условия отбора событий; nil — без ограничения
+type Filter struct {
+	UserID *uint
+	TaskID *uint
+}
+
+// Событие подходит, если совпадают все заданные поля.
+// У событий задач нет пользователя, поэтому фильтр по пользователю их исключает.
+func (f Filter) Match(event models.Event) bool {
+	if f.UserID != nil && (event.UserID == nil || *event.UserID != *f.UserID) {
+		return false
+	}
+	if f.TaskID != nil && event.TaskID != *f.TaskID {
+		return false
+	}
+	return true
+}
+
+// Подписка на новые события. Events закрывается, если подписчик не успевает их читать.
+type Subscription struct {
+	Events <-chan models.Event
+
+	events chan models.Event
+	filter Filter
+}
+
+// Рассылка событий подписчикам потоков этого процесса
+type Hub struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+// Рассылка события подходящим подписчикам
+func (h *Hub) Publish(event models.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		if !sub.filter.Match(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(h.subs, sub)
+			close(sub.events)
+		}
+	}
+}
+
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := make(chan models.Event, subscriberBuffer)
+	sub := &Subscription{Events: events, events: events, filter: filter}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+// Отмена подписки; повторный вызов ничего не делает
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
@@ -0,0 +1,127 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Событие календаря с точностью до дня: End не входит в событие
+type Event struct {
+	Summary string
+	Start   time.Time
+	End     time.Time
+}
+
+// Разбор событий VEVENT из файла iCalendar (RFC 5545). Учитываются только
+// DTSTART, DTEND и SUMMARY; время событий отбрасывается, повторения (RRULE) не разворачиваются.
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	var event *Event
+
+	for i, line := range lines {
+		name, params, value, ok := splitLine(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			event = &Event{}
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if event == nil {
+				return nil, fmt.Errorf("line %d: END:VEVENT without BEGIN", i+1)
+			}
+			if event.Start.IsZero() {
+				return nil, fmt.Errorf("line %d: event without DTSTART", i+1)
+			}
+			if !event.End.After(event.Start) {
+				event.End = event.Start.AddDate(0, 0, 1)
+			}
+			events = append(events, *event)
+			event = nil
+		case event == nil:
+		case name == "SUMMARY":
+			event.Summary = unescape(value)
+		case name == "DTSTART" || name == "DTEND":
+			date, err := parseDate(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			if name == "DTSTART" {
+				event.Start = date
+			} else {
+				event.End = date
+			}
+		}
+	}
+
+	if event != nil {
+		return nil, fmt.Errorf("unterminated VEVENT")
+	}
+
+	return events, nil
+}
+
+// Склейка строк, перенесённых по правилам RFC 5545 (продолжение начинается с пробела или табуляции)
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+// Строка вида NAME;PARAM=VALUE:value
+func splitLine(line string) (name string, params map[string]string, value string, ok bool) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", nil, "", false
+	}
+
+	parts := strings.Split(head, ";")
+	params = make(map[string]string)
+	for _, param := range parts[1:] {
+		key, val, _ := strings.Cut(param, "=")
+		params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+	}
+
+	return strings.ToUpper(parts[0]), params, value, true
+}
+
+// Дата события: VALUE=DATE (20240101) или DATE-TIME (20240101T090000Z), от которого берётся только дата
+func parseDate(value string, params map[string]string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+
+	date, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	if params["VALUE"] == "DATE" && len(value) != 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+
+	return date, nil
+}
+
+func unescape(value string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
@@ -0,0 +1,111 @@
+package ical
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func calendar(lines ...string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VCALENDAR\r\n"
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		ics  string
+		want []Event
+	}{
+		{
+			name: "all-day event without DTEND lasts one day",
+			ics:  calendar("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20240101", "SUMMARY:Новый год", "END:VEVENT"),
+			want: []Event{{Summary: "Новый год", Start: date(2024, 1, 1), End: date(2024, 1, 2)}},
+		},
+		{
+			name: "multi-day event keeps exclusive DTEND",
+			ics:  calendar("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20240501", "DTEND;VALUE=DATE:20240504", "SUMMARY:Майские", "END:VEVENT"),
+			want: []Event{{Summary: "Майские", Start: date(2024, 5, 1), End: date(2024, 5, 4)}},
+		},
+		{
+			name: "date-time values are truncated to dates",
+			ics:  calendar("BEGIN:VEVENT", "DTSTART;TZID=Europe/Moscow:20240308T090000", "DTEND:20240308T180000Z", "SUMMARY:Short day", "END:VEVENT"),
+			want: []Event{{Summary: "Short day", Start: date(2024, 3, 8), End: date(2024, 3, 9)}},
+		},
+		{
+			name: "folded lines are joined",
+			ics: calendar("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20240612", "SUMMARY:День ", " России", "\tи выходной",
+				"END:VEVENT"),
+			want: []Event{{Summary: "День Россиии выходной", Start: date(2024, 6, 12), End: date(2024, 6, 13)}},
+		},
+		{
+			name: "escaped text is unescaped",
+			ics:  calendar("BEGIN:VEVENT", `SUMMARY:Office closed\, moving\; see\nnotes \\ wiki`, "DTSTART;VALUE=DATE:20240102", "END:VEVENT"),
+			want: []Event{{Summary: `Office closed, moving; see notes \ wiki`, Start: date(2024, 1, 2), End: date(2024, 1, 3)}},
+		},
+		{
+			name: "RRULE is not expanded",
+			ics:  calendar("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20240101", "RRULE:FREQ=YEARLY", "SUMMARY:Every year", "END:VEVENT"),
+			want: []Event{{Summary: "Every year", Start: date(2024, 1, 1), End: date(2024, 1, 2)}},
+		},
+		{
+			name: "properties outside events and lowercase names are handled",
+			ics: calendar("SUMMARY:Calendar", "begin:VEVENT", "dtstart;value=DATE:20241104", "summary:Unity Day", "end:VEVENT",
+				"BEGIN:VEVENT", "DTSTART;VALUE=DATE:20241231", "END:VEVENT"),
+			want: []Event{
+				{Summary: "Unity Day", Start: date(2024, 11, 4), End: date(2024, 11, 5)},
+				{Start: date(2024, 12, 31), End: date(2025, 1, 1)},
+			},
+		},
+		{
+			name: "LF line endings",
+			ics:  "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20240101\nEND:VEVENT\nEND:VCALENDAR\n",
+			want: []Event{{Start: date(2024, 1, 1), End: date(2024, 1, 2)}},
+		},
+		{
+			name: "calendar without events",
+			ics:  calendar(),
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.ics))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		ics  string
+		want string
+	}{
+		{"missing DTSTART", calendar("BEGIN:VEVENT", "SUMMARY:x", "END:VEVENT"), "without DTSTART"},
+		{"END without BEGIN", calendar("END:VEVENT"), "without BEGIN"},
+		{"unterminated event", "BEGIN:VEVENT\r\nDTSTART:20240101\r\n", "unterminated"},
+		{"invalid date", calendar("BEGIN:VEVENT", "DTSTART:2024-01-01", "END:VEVENT"), "invalid date"},
+		{"DATE with time", calendar("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20240101T090000", "END:VEVENT"), "invalid date"},
+		{"short date", calendar("BEGIN:VEVENT", "DTSTART:2024", "END:VEVENT"), "invalid date"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.ics))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
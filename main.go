@@ -2,8 +2,12 @@ package main
 
 import (
 	"em-test/config"
+	"em-test/controllers"
 	"em-test/router"
+	"em-test/scheduler"
 	"em-test/validators"
+	"log"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 
@@ -12,10 +16,23 @@ import (
 
 
 func main() {
+	config.InitWorkflow()
 	config.InitDB()
+	config.InitSettings()
+	config.InitStorage()
+	config.InitBroker()
 
 	validate := validator.New()
 	validate.RegisterValidation("passport_number_format", validators.ValidatePassportNumberFormat)
+	validate.RegisterValidation("recurrence", validators.ValidateRecurrence)
+
+	scheduler.Every(time.Minute, "task templates", controllers.MaterializeTaskTemplates)
+	scheduler.Every(time.Minute, "timer auto-stop", controllers.AutoStopTimers)
+	if err := controllers.StartEventStream(); err != nil {
+		log.Fatalf("failed to subscribe to events: %v", err)
+	}
+	scheduler.Every(time.Second, "outbox relay", controllers.RelayOutbox)
+	scheduler.Every(10*time.Second, "webhook deliveries", controllers.DeliverWebhooks)
 
 	r := router.SetupRouter(validate)
 
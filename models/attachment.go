@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Вложение задачи; содержимое лежит в хранилище под ключом StorageKey
+type Attachment struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	TaskID      uint      `gorm:"index" json:"task_id"`
+	UploaderID  *uint     `json:"uploader_id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `gorm:"not null;uniqueIndex" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
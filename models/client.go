@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type Client struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `json:"name" validate:"required"`
+	Version   uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
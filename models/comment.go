@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Комментарий к задаче; ответы ссылаются на родительский комментарий через ParentID
+type Comment struct {
+	ID        uint      `gorm:"primaryKey;index:idx_comments_task_id_created_at,priority:3" json:"id"`
+	TaskID    uint      `gorm:"index:idx_comments_task_id_created_at,priority:1" json:"task_id"`
+	ParentID  *uint     `gorm:"index" json:"parent_id"`
+	AuthorID  uint      `gorm:"index" json:"author_id" validate:"required"`
+	Body      string    `json:"body" validate:"required,max=10000"`
+	Mentions  []uint    `gorm:"-" json:"mentions"`
+	Deleted   bool      `gorm:"not null;default:false" json:"deleted"`
+	Version   uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt time.Time `gorm:"index:idx_comments_task_id_created_at,priority:2" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Упоминание пользователя в комментарии (@userId)
+type CommentMention struct {
+	CommentID uint `gorm:"primaryKey" json:"comment_id"`
+	UserID    uint `gorm:"primaryKey;index" json:"user_id"`
+}
+
+type CommentUpdateRequest struct {
+	UserID uint   `json:"user_id" validate:"required"`
+	Body   string `json:"body" validate:"required,max=10000"`
+}
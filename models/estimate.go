@@ -0,0 +1,13 @@
+package models
+
+// Оценка задачи и фактически затраченное время в минутах; для превысивших оценку
+// заполняется OverrunPercent, для остальных — RemainingMinutes
+type TaskEstimate struct {
+	TaskID           uint    `json:"task_id"`
+	Title            string  `json:"title"`
+	Status           string  `json:"status"`
+	EstimateMinutes  int     `json:"estimate_minutes"`
+	ActualMinutes    int     `json:"actual_minutes"`
+	RemainingMinutes int     `json:"remaining_minutes"`
+	OverrunPercent   float64 `json:"overrun_percent"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+const (
+	EventTimerStarted = "timer.started"
+	EventTimerStopped = "timer.stopped"
+	EventTaskCreated  = "task.created"
+	EventTaskUpdated  = "task.updated"
+	EventUserCreated  = "user.created"
+	// Проверочное событие вебхука, отправляется только по запросу
+	EventWebhookTest = "webhook.test"
+	// Служебное событие: часть событий потеряна, клиенту нужно перечитать данные
+	EventResync = "resync"
+)
+
+// Событие потока изменений. Data — таймер (TaskLog), задача (Task) или пользователь (User) на момент события.
+type Event struct {
+	ID     uint64      `json:"id"`
+	Type   string      `json:"type" example:"timer.started"`
+	UserID *uint       `json:"user_id"`
+	TaskID uint        `json:"task_id"`
+	Time   time.Time   `json:"time"`
+	Data   interface{} `json:"data"`
+}
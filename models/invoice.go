@@ -0,0 +1,54 @@
+package models
+
+import (
+	"em-test/money"
+	"time"
+)
+
+const (
+	InvoiceIssued = "issued"
+	InvoiceVoid   = "void"
+)
+
+// Счёт за период; строки и сумма фиксируются при выставлении
+type Invoice struct {
+	ID         uint          `gorm:"primaryKey" json:"id"`
+	Number     string        `gorm:"not null;uniqueIndex" json:"number" example:"INV-000001"`
+	ClientID   *uint         `gorm:"index" json:"client_id"`
+	UserID     *uint         `gorm:"index" json:"user_id"`
+	PeriodFrom time.Time     `json:"period_from"`
+	PeriodTo   time.Time     `json:"period_to"`
+	Currency   string        `gorm:"not null;size:3" json:"currency"`
+	Total      money.Amount  `gorm:"not null" json:"total" swaggertype:"string" example:"120.50"`
+	Status     string        `gorm:"not null;default:issued;index" json:"status"`
+	Lines      []InvoiceLine `json:"lines"`
+	Version    uint          `gorm:"not null;default:1" json:"version"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+// Строка счёта: время по задаче по одной ставке
+type InvoiceLine struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	InvoiceID   uint         `gorm:"index" json:"invoice_id"`
+	TaskID      uint         `json:"task_id"`
+	Description string       `json:"description"`
+	Minutes     int          `json:"minutes"`
+	Rate        money.Amount `gorm:"not null" json:"rate" swaggertype:"string" example:"120.50"`
+	Amount      money.Amount `gorm:"not null" json:"amount" swaggertype:"string" example:"120.50"`
+}
+
+// Счётчик номеров счетов; единственная строка блокируется на время выставления счёта
+type InvoiceSequence struct {
+	ID    uint `gorm:"primaryKey"`
+	Value uint `gorm:"not null"`
+}
+
+type InvoiceRequest struct {
+	ClientID *uint  `json:"client_id"`
+	UserID   *uint  `json:"user_id"`
+	From     string `json:"from" validate:"required,datetime=2006-01-02" example:"2024-01-01"`
+	To       string `json:"to" validate:"required,datetime=2006-01-02" example:"2024-01-31"`
+	Currency string `json:"currency" validate:"required,len=3,uppercase" example:"EUR"`
+	TimeZone string `json:"time_zone" validate:"omitempty,timezone" example:"Asia/Vladivostok"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Событие, записанное в той же транзакции, что и изменение. Id становится id события
+// в потоках и вебхуках. Sequence — порядок передачи брокеру: id выдаются до фиксации
+// транзакций и могут появляться не по порядку, поэтому потоки возобновляются по Sequence.
+// PublishedAt заполняется после передачи события брокеру.
+type OutboxEvent struct {
+	ID          uint64 `gorm:"primaryKey"`
+	Type        string `gorm:"not null"`
+	UserID      *uint  `gorm:"index"`
+	TaskID      uint   `gorm:"index"`
+	Data        string `gorm:"not null"`
+	CreatedAt   time.Time
+	Sequence    *uint64    `gorm:"uniqueIndex"`
+	PublishedAt *time.Time `gorm:"index"`
+}
+
+// Состояние relay в единственной строке: счётчик Sequence и аренда, пока которой
+// события передаёт брокеру только одна реплика
+type OutboxRelay struct {
+	ID          uint      `gorm:"primaryKey"`
+	Sequence    uint64    `gorm:"not null"`
+	LeasedUntil time.Time `gorm:"not null"`
+}
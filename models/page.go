@@ -0,0 +1,17 @@
+package models
+
+type Page[T any] struct {
+	Items    []T     `json:"items"`
+	Total    int64   `json:"total"`
+	Page     int     `json:"page"`
+	PageSize int     `json:"page_size"`
+	Next     *string `json:"next"`
+	Prev     *string `json:"prev"`
+}
+
+type CursorPage[T any] struct {
+	Items      []T     `json:"items"`
+	PageSize   int     `json:"page_size"`
+	NextCursor *string `json:"next_cursor"`
+	Next       *string `json:"next"`
+}
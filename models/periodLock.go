@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Закрытый период: логи, начатые до LockedUntil, нельзя создавать и изменять.
+// Блокировка без пользователя действует для всех.
+type PeriodLock struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      *uint     `gorm:"index" json:"user_id"`
+	LockedUntil time.Time `gorm:"not null;index" json:"locked_until" validate:"required" example:"2024-02-01T00:00:00Z"`
+	Reason      string    `json:"reason" validate:"max=500"`
+	Version     uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
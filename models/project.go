@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type Project struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ClientID    uint      `gorm:"index" json:"client_id" validate:"required"`
+	Name        string    `json:"name" validate:"required"`
+	Description string    `json:"description"`
+	Version     uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
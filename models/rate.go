@@ -0,0 +1,32 @@
+package models
+
+import (
+	"em-test/money"
+	"time"
+)
+
+// Часовая ставка. Область действия задаётся пользователем, задачей или проектом;
+// ставка без области — ставка по умолчанию. Действует с EffectiveFrom до следующей
+// ставки с той же областью.
+type Rate struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	UserID        *uint        `gorm:"index" json:"user_id"`
+	ProjectID     *uint        `gorm:"index" json:"project_id" validate:"excluded_with=TaskID"`
+	TaskID        *uint        `gorm:"index" json:"task_id"`
+	Amount        money.Amount `gorm:"not null" json:"amount" validate:"gt=0" swaggertype:"string" example:"120.50"`
+	Currency      string       `gorm:"not null;size:3" json:"currency" validate:"required,len=3,uppercase" example:"EUR"`
+	EffectiveFrom time.Time    `gorm:"not null;index" json:"effective_from" validate:"required"`
+	Version       uint         `gorm:"not null;default:1" json:"version"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// Стоимость в одной валюте
+type Cost struct {
+	Currency string       `json:"currency"`
+	Amount   money.Amount `json:"amount" swaggertype:"string" example:"120.50"`
+}
+
+type BillableRequest struct {
+	Billable *bool `json:"billable" validate:"required"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+const (
+	RoundingNone    = "none"
+	RoundingUp      = "up"
+	RoundingDown    = "down"
+	RoundingNearest = "nearest"
+)
+
+// Правило округления времени лога в отчётах. Правило без проекта действует
+// для всех проектов, у которых нет своего.
+type RoundingPolicy struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ProjectID      *uint     `gorm:"index" json:"project_id"`
+	Mode           string    `gorm:"not null" json:"mode" validate:"required,oneof=none up down nearest"`
+	Increment      int       `gorm:"not null" json:"increment" validate:"required_unless=Mode none,min=0,max=1440" example:"15"`
+	MinimumMinutes int       `gorm:"not null" json:"minimum_minutes" validate:"min=0,max=1440" example:"15"`
+	Version        uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+const (
+	AbsenceVacation  = "vacation"
+	AbsenceSickLeave = "sick_leave"
+	AbsenceOther     = "other"
+)
+
+// Рабочий график пользователя: часы по дням недели, начиная с понедельника
+type WorkSchedule struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Hours     []float64 `gorm:"serializer:json;not null" json:"hours" validate:"len=7,dive,min=0,max=24" example:"8,8,8,8,8,0,0"`
+	Version   uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Праздничный день, общий для всех пользователей
+type Holiday struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Date      time.Time `gorm:"not null;uniqueIndex" json:"date" validate:"required" example:"2024-01-01T00:00:00Z"`
+	Name      string    `json:"name" validate:"required,max=200"`
+	Version   uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Отсутствие пользователя с StartDate по EndDate включительно
+type Absence struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id" validate:"required"`
+	Type      string    `gorm:"not null" json:"type" validate:"required,oneof=vacation sick_leave other"`
+	StartDate time.Time `gorm:"not null;index" json:"start_date" validate:"required" example:"2024-07-01T00:00:00Z"`
+	EndDate   time.Time `gorm:"not null;index" json:"end_date" validate:"required,gtefield=StartDate" example:"2024-07-14T00:00:00Z"`
+	Note      string    `json:"note" validate:"max=500"`
+	Version   uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Плановое и фактическое время пользователя за период
+type Capacity struct {
+	UserID             uint     `json:"user_id"`
+	Name               string   `json:"name"`
+	ScheduledMinutes   int      `json:"scheduled_minutes"`
+	HolidayMinutes     int      `json:"holiday_minutes"`
+	AbsenceMinutes     int      `json:"absence_minutes"`
+	ExpectedMinutes    int      `json:"expected_minutes"`
+	LoggedMinutes      int      `json:"logged_minutes"`
+	OvertimeMinutes    int      `json:"overtime_minutes"`
+	UtilizationPercent *float64 `json:"utilization_percent"`
+}
+
+type CapacityReport struct {
+	StartDate time.Time  `json:"start_date"`
+	EndDate   time.Time  `json:"end_date"`
+	Users     []Capacity `json:"users"`
+}
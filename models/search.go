@@ -0,0 +1,9 @@
+package models
+
+type SearchResult struct {
+	Type    string  `json:"type"`
+	ID      uint    `json:"id"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"uniqueIndex;not null" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TaskTag struct {
+	TaskID uint `gorm:"primaryKey"`
+	TagID  uint `gorm:"primaryKey;index"`
+}
+
+type TaskLogTag struct {
+	TaskLogID uint `gorm:"primaryKey"`
+	TagID     uint `gorm:"primaryKey;index"`
+}
+
+type TagsRequest struct {
+	Tags []string `json:"tags" validate:"dive,max=50"`
+}
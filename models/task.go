@@ -4,8 +4,15 @@ import "time"
 
 type Task struct {
 	ID 					uint			`gorm:"primaryKey" json:"id"`
+	ProjectID   *uint     `gorm:"index" json:"project_id"`
+	ParentID    *uint     `gorm:"index" json:"parent_id"`
 	Title       string    `json:"title" validate:"required"`
   Description string    `json:"description" validate:"required"`
+	Status      string    `gorm:"not null;default:'';index" json:"status"`
+	EstimateMinutes *int  `json:"estimate_minutes" validate:"omitempty,min=1"`
+	Tags        []string  `gorm:"-" json:"tags" validate:"omitempty,dive,max=50"`
+	CommentCount *int64   `gorm:"-" json:"comment_count,omitempty"`
+	Version     uint      `gorm:"not null;default:1" json:"version"`
 	CreatedAt   time.Time `json:"created_at"`
   UpdatedAt   time.Time `json:"updated_at"`
 }
\ No newline at end of file
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type TaskAssignee struct {
+	TaskID    uint      `gorm:"primaryKey" json:"task_id"`
+	UserID    uint      `gorm:"primaryKey;index" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AssigneesRequest struct {
+	UserIDs []uint `json:"user_ids" validate:"required,min=1"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Задача TaskID заблокирована задачей BlockedByID
+type TaskDependency struct {
+	TaskID      uint      `gorm:"primaryKey" json:"task_id"`
+	BlockedByID uint      `gorm:"primaryKey;index" json:"blocked_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type DependencyRequest struct {
+	BlockedByID uint `json:"blocked_by_id" validate:"required"`
+}
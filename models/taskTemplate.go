@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Шаблон повторяющейся задачи; планировщик создаёт по нему задачи по правилу Recurrence
+type TaskTemplate struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	ProjectID         *uint     `gorm:"index" json:"project_id"`
+	Title             string    `json:"title" validate:"required"`
+	Description       string    `json:"description" validate:"required"`
+	EstimateMinutes   *int      `json:"estimate_minutes" validate:"omitempty,min=1"`
+	Tags              []string  `gorm:"serializer:json" json:"tags" validate:"omitempty,dive,max=50"`
+	Recurrence        string    `gorm:"not null" json:"recurrence" validate:"required,recurrence" example:"0 9 * * 1"`
+	Paused            bool      `gorm:"not null;default:false" json:"paused"`
+	MaterializedUntil time.Time `gorm:"not null" json:"materialized_until"`
+	Version           uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// Созданная по шаблону задача; первичный ключ не даёт создать одно срабатывание дважды
+type TaskOccurrence struct {
+	TemplateID   uint      `gorm:"primaryKey" json:"template_id"`
+	OccurrenceAt time.Time `gorm:"primaryKey" json:"occurrence_at"`
+	TaskID       uint      `gorm:"uniqueIndex" json:"task_id"`
+}
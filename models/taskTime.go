@@ -1,8 +1,28 @@
 package models
 
+// Hours и Minutes — время в целых минутах, Rounded — по правилам округления
 type TaskTime struct {
-	TaskID  uint   `json:"task_id"`
-	Title   string `json:"title"`
-	Hours   int    `json:"hours"`
-	Minutes int    `json:"minutes"`
+	TaskID              uint   `json:"task_id"`
+	ParentID            *uint  `json:"parent_id"`
+	Title               string `json:"title"`
+	Hours               int    `json:"hours"`
+	Minutes             int    `json:"minutes"`
+	TotalHours          int    `json:"total_hours"`
+	TotalMinutes        int    `json:"total_minutes"`
+	RoundedHours        int    `json:"rounded_hours"`
+	RoundedMinutes      int    `json:"rounded_minutes"`
+	RoundedTotalHours   int    `json:"rounded_total_hours"`
+	RoundedTotalMinutes int    `json:"rounded_total_minutes"`
+	Cost                []Cost `json:"cost"`
+	TotalCost           []Cost `json:"total_cost"`
+}
+
+type GroupTime struct {
+	ID             uint   `json:"id"`
+	Name           string `json:"name"`
+	Hours          int    `json:"hours"`
+	Minutes        int    `json:"minutes"`
+	RoundedHours   int    `json:"rounded_hours"`
+	RoundedMinutes int    `json:"rounded_minutes"`
+	Cost           []Cost `json:"cost"`
 }
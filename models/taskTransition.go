@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type TaskTransition struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TaskID     uint      `gorm:"index" json:"task_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	UserID     *uint     `json:"user_id"`
+	Comment    string    `json:"comment"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type TransitionRequest struct {
+	To      string `json:"to" validate:"required"`
+	UserID  *uint  `json:"user_id"`
+	Comment string `json:"comment"`
+}
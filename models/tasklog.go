@@ -3,11 +3,16 @@ package models
 import "time"
 
 type TaskLog struct {
-	ID 					uint 				`gorm:"primaryKey" json:"id"`
-	TaskID 			uint 				`json:"task_id" validate:"required"`
-	UserID 			uint 				`json:"user_id" validate:"required"`
-	StartTime 	time.Time 	`json:"start_time"`
+	ID 					uint 				`gorm:"primaryKey;index:idx_task_logs_start_time_id,priority:2" json:"id"`
+	TaskID 			uint 				`gorm:"index" json:"task_id" validate:"required"`
+	UserID 			uint 				`gorm:"index:idx_task_logs_user_id_start_time,priority:1" json:"user_id" validate:"required"`
+	StartTime 	time.Time 	`gorm:"index:idx_task_logs_start_time_id,priority:1;index:idx_task_logs_user_id_start_time,priority:2" json:"start_time"`
   EndTime   	time.Time 	`json:"end_time"`
+	Tags 				[]string 		`gorm:"-" json:"tags" validate:"omitempty,dive,max=50"`
+	Billable 		*bool 			`gorm:"not null;default:true" json:"billable"`
+	InvoiceID 	*uint 			`gorm:"index" json:"invoice_id"`
+	AutoStopped	bool				`gorm:"not null;default:false" json:"auto_stopped"`
+	Version 		uint 				`gorm:"not null;default:1" json:"version"`
   CreatedAt 	time.Time 	`json:"created_at"`
   UpdatedAt 	time.Time 	`json:"updated_at"`
 }
\ No newline at end of file
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+const (
+	TimesheetDraft     = "draft"
+	TimesheetSubmitted = "submitted"
+	TimesheetApproved  = "approved"
+	TimesheetRejected  = "rejected"
+)
+
+// Недельный табель пользователя. Логи недели с отправленным или утверждённым
+// табелем изменять нельзя.
+type Timesheet struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"not null;uniqueIndex:idx_timesheet_week" json:"user_id"`
+	WeekStart       time.Time  `gorm:"not null;uniqueIndex:idx_timesheet_week" json:"week_start"`
+	WeekEnd         time.Time  `gorm:"not null" json:"week_end"`
+	Status          string     `gorm:"not null;default:draft;index" json:"status"`
+	RejectionReason string     `json:"rejection_reason"`
+	SubmittedAt     *time.Time `json:"submitted_at"`
+	ReviewerID      *uint      `json:"reviewer_id"`
+	ReviewedAt      *time.Time `json:"reviewed_at"`
+	TotalMinutes    *int       `gorm:"-" json:"total_minutes,omitempty"`
+	TaskLogs        []TaskLog  `gorm:"-" json:"task_logs,omitempty"`
+	Version         uint       `gorm:"not null;default:1" json:"version"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type TimesheetRequest struct {
+	UserID    uint   `json:"user_id" validate:"required"`
+	WeekStart string `json:"week_start" validate:"required,datetime=2006-01-02" example:"2024-01-08"`
+}
+
+type TimesheetSubmitRequest struct {
+	UserID uint `json:"user_id" validate:"required"`
+}
+
+// Решение руководителя по табелю. Пока в сервисе нет аутентификации,
+// ManagerID указывает клиент и сервер ему доверяет.
+type TimesheetReviewRequest struct {
+	ManagerID uint   `json:"manager_id" validate:"required"`
+	Reason    string `json:"reason" validate:"max=1000"`
+}
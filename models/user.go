@@ -9,6 +9,10 @@ type User struct {
 	Patronymic			string	  `json:"patronymic" validate:"required"`
 	Address					string	  `json:"address" validate:"required"`
 	PassportNumber	string		`json:"passport_number" validate:"required,passport_number_format"`
+	ManagerID			*uint			`gorm:"index" json:"manager_id"`
+	WorkdayEnd		*string		`gorm:"size:5" json:"workday_end" validate:"omitempty,datetime=15:04" example:"18:00"`
+	TimeZone		string		`gorm:"not null;default:UTC" json:"time_zone" validate:"omitempty,timezone" example:"Asia/Vladivostok"`
+	Version					uint			`gorm:"not null;default:1" json:"version"`
 	CreatedAt     	time.Time `json:"created_at"`
 	UpdatedAt     	time.Time `json:"updated_at"`
 }
\ No newline at end of file
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// Подписка на события: на URL отправляются события перечисленных типов,
+// подписанные секретом. Секрет в ответах не возвращается, см. WebhookWithSecret.
+type Webhook struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	URL        string    `gorm:"not null" json:"url" validate:"required,http_url,max=2000" example:"https://example.com/hooks/time"`
+	EventTypes []string  `gorm:"serializer:json;not null" json:"event_types" validate:"required,min=1,dive,oneof=timer.started timer.stopped task.created task.updated user.created" example:"timer.stopped,user.created"`
+	Secret     string    `gorm:"not null" json:"-"`
+	Active     *bool     `gorm:"not null;default:true" json:"active"`
+	Version    uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Вебхук вместе с секретом: тело создания и изменения и ответ на создание —
+// единственный ответ, в котором секрет виден
+type WebhookWithSecret struct {
+	Webhook
+	Secret string `json:"secret" validate:"omitempty,min=16,max=200"`
+}
+
+// Доставка события вебхуку и её журнал. Неудачные попытки повторяются
+// с растущей паузой; после последней доставка становится dead.
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	WebhookID      uint       `gorm:"not null;index" json:"webhook_id"`
+	EventID        uint64     `gorm:"not null" json:"event_id"`
+	EventType      string     `gorm:"not null" json:"event_type"`
+	Payload        string     `gorm:"not null" json:"payload"`
+	Status         string     `gorm:"not null;default:pending;index" json:"status"`
+	Attempts       int        `gorm:"not null" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"not null;index" json:"next_attempt_at"`
+	LastError      string     `json:"last_error"`
+	ResponseStatus int        `json:"response_status"`
+	Response       string     `json:"response"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
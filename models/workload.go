@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+type WorkloadTask struct {
+	TaskID  uint   `json:"task_id"`
+	Title   string `json:"title"`
+	Status  string `json:"status"`
+	Hours   int    `json:"hours"`
+	Minutes int    `json:"minutes"`
+}
+
+type Workload struct {
+	UserID    uint           `json:"user_id"`
+	Name      string         `json:"name"`
+	OpenTasks []WorkloadTask `json:"open_tasks"`
+	Hours     int            `json:"hours"`
+	Minutes   int            `json:"minutes"`
+}
+
+type WorkloadReport struct {
+	WeekStart time.Time  `json:"week_start"`
+	WeekEnd   time.Time  `json:"week_end"`
+	Users     []Workload `json:"users"`
+}
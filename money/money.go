@@ -0,0 +1,101 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Сумма не помещается в int64
+var ErrOverflow = errors.New("amount is too large")
+
+// Денежная сумма в сотых долях валюты. Хранится целым числом,
+// чтобы суммирование не накапливало ошибок округления.
+type Amount int64
+
+// Разбор суммы вида "120", "120.5" или "120.50"
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	whole, frac, _ := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+
+	if whole == "" || len(frac) > 2 || strings.ContainsAny(whole+frac, "+-") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	var cents int64
+	if frac != "" {
+		frac += strings.Repeat("0", 2-len(frac))
+		if cents, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+
+	if units > (math.MaxInt64-cents)/100 {
+		return 0, fmt.Errorf("amount %q is too large", s)
+	}
+
+	amount := Amount(units*100 + cents)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+func (a Amount) String() string {
+	sign := ""
+	if a < 0 {
+		sign, a = "-", -a
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, a/100, a%100)
+}
+
+// Стоимость minutes минут по часовой ставке a, с округлением до сотых половины вверх
+func (a Amount) ForMinutes(minutes int) (Amount, error) {
+	total := int64(a) * int64(minutes)
+	if minutes != 0 && (total/int64(minutes) != int64(a) || (minutes == -1 && a == math.MinInt64)) {
+		return 0, ErrOverflow
+	}
+
+	// Деление до округления, чтобы total*2 не переполнялось
+	quotient, remainder := total/60, total%60
+	switch {
+	case remainder >= 30:
+		quotient++
+	case remainder <= -30:
+		quotient--
+	}
+	return Amount(quotient), nil
+}
+
+// Сумма a и b
+func (a Amount) Add(b Amount) (Amount, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, ErrOverflow
+	}
+	return sum, nil
+}
+
+// В JSON сумма передаётся строкой, чтобы не терять точность в клиентах с float
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	raw := strings.Trim(string(data), `"`)
+	amount, err := Parse(raw)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
@@ -0,0 +1,57 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestForMinutes(t *testing.T) {
+	tests := []struct {
+		rate    Amount
+		minutes int
+		want    Amount
+		err     error
+	}{
+		{rate: 12050, minutes: 60, want: 12050},
+		{rate: 12050, minutes: 90, want: 18075},
+		{rate: 100, minutes: 1, want: 2},
+		{rate: 1, minutes: 29},
+		{rate: 1, minutes: 30, want: 1},
+		{rate: -1, minutes: 30, want: -1},
+		{rate: -100, minutes: 1, want: -2},
+		{rate: 12050, minutes: 0},
+		{rate: math.MaxInt64, minutes: 1, want: math.MaxInt64 / 60},
+		{rate: math.MaxInt64, minutes: 2, err: ErrOverflow},
+		{rate: math.MaxInt64 / 1000, minutes: 1_000_000, err: ErrOverflow},
+		{rate: math.MinInt64, minutes: -1, err: ErrOverflow},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.rate.ForMinutes(tt.minutes)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("Amount(%d).ForMinutes(%d) = %d, %v, want %d, %v", tt.rate, tt.minutes, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		a, b Amount
+		want Amount
+		err  error
+	}{
+		{a: 100, b: 250, want: 350},
+		{a: 100, b: -250, want: -150},
+		{a: math.MaxInt64 - 1, b: 1, want: math.MaxInt64},
+		{a: math.MaxInt64, b: 1, err: ErrOverflow},
+		{a: math.MinInt64, b: -1, err: ErrOverflow},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.a.Add(tt.b)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("Amount(%d).Add(%d) = %d, %v, want %d, %v", tt.a, tt.b, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Размер em, в котором PDF задаёт ширины глифов
+const glyphSpace = 1000
+
+// Шрифты Go покрывают латиницу и кириллицу и встраиваются в документ целиком
+var (
+	regularFont = mustParseFont(goregular.TTF)
+	boldFont    = mustParseFont(gobold.TTF)
+)
+
+// Встраиваемый шрифт TrueType с метриками в единицах glyphSpace
+type embeddedFont struct {
+	name      string
+	data      []byte
+	sfnt      *sfnt.Font
+	bbox      [4]int
+	ascent    int
+	descent   int
+	capHeight int
+}
+
+func mustParseFont(data []byte) *embeddedFont {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		panic(fmt.Sprintf("pdf: parse font: %v", err))
+	}
+
+	var b sfnt.Buffer
+	ppem := fixed.I(glyphSpace)
+	name, err := f.Name(&b, sfnt.NameIDPostScript)
+	if err != nil {
+		panic(fmt.Sprintf("pdf: font name: %v", err))
+	}
+	bounds, err := f.Bounds(&b, ppem, font.HintingNone)
+	if err != nil {
+		panic(fmt.Sprintf("pdf: font bounds: %v", err))
+	}
+	metrics, err := f.Metrics(&b, ppem, font.HintingNone)
+	if err != nil {
+		panic(fmt.Sprintf("pdf: font metrics: %v", err))
+	}
+
+	// Ось Y в sfnt направлена вниз, в PDF — вверх
+	return &embeddedFont{
+		name:      name,
+		data:      data,
+		sfnt:      f,
+		bbox:      [4]int{bounds.Min.X.Round(), -bounds.Max.Y.Round(), bounds.Max.X.Round(), -bounds.Min.Y.Round()},
+		ascent:    metrics.Ascent.Round(),
+		descent:   -metrics.Descent.Round(),
+		capHeight: metrics.CapHeight.Round(),
+	}
+}
+
+// Номер глифа для символа; символы, которых нет в шрифте, выводятся как '?'
+func (f *embeddedFont) glyph(b *sfnt.Buffer, r rune) (sfnt.GlyphIndex, rune) {
+	if r >= 32 {
+		if gid, err := f.sfnt.GlyphIndex(b, r); err == nil && gid != 0 {
+			return gid, r
+		}
+	}
+	gid, _ := f.sfnt.GlyphIndex(b, '?')
+	return gid, '?'
+}
+
+func (f *embeddedFont) width(b *sfnt.Buffer, gid sfnt.GlyphIndex) int {
+	advance, err := f.sfnt.GlyphAdvance(b, gid, fixed.I(glyphSpace), font.HintingNone)
+	if err != nil {
+		return 0
+	}
+	return advance.Round()
+}
+
+// Глифы шрифта, использованные в документе, и символы, которые они выводят
+type fontUsage struct {
+	font   *embeddedFont
+	glyphs map[sfnt.GlyphIndex]rune
+}
+
+func newFontUsage(f *embeddedFont) *fontUsage {
+	return &fontUsage{font: f, glyphs: make(map[sfnt.GlyphIndex]rune)}
+}
+
+// Строка в шестнадцатеричной записи номеров глифов для кодировки Identity-H
+func (u *fontUsage) encode(text string) string {
+	var b sfnt.Buffer
+	var out strings.Builder
+	out.WriteByte('<')
+	for _, r := range text {
+		gid, shown := u.font.glyph(&b, r)
+		u.glyphs[gid] = shown
+		fmt.Fprintf(&out, "%04X", uint16(gid))
+	}
+	out.WriteByte('>')
+	return out.String()
+}
+
+func (u *fontUsage) sortedGlyphs() []sfnt.GlyphIndex {
+	gids := make([]sfnt.GlyphIndex, 0, len(u.glyphs))
+	for gid := range u.glyphs {
+		gids = append(gids, gid)
+	}
+	sort.Slice(gids, func(i, j int) bool { return gids[i] < gids[j] })
+	return gids
+}
+
+// Объекты шрифта начиная с номера first: Type0, CIDFontType2, дескриптор,
+// файл шрифта и таблица ToUnicode для копирования и поиска текста
+func (u *fontUsage) objects(first int) []string {
+	f := u.font
+	var b sfnt.Buffer
+
+	var widths strings.Builder
+	for _, gid := range u.sortedGlyphs() {
+		fmt.Fprintf(&widths, "%d [%d] ", gid, f.width(&b, gid))
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(f.data)
+	zw.Close()
+
+	toUnicode := u.toUnicode()
+
+	return []string{
+		fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+			f.name, first+1, first+4),
+		fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /W [%s] /CIDToGIDMap /Identity >>",
+			f.name, first+2, strings.TrimSpace(widths.String())),
+		fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+			f.name, f.bbox[0], f.bbox[1], f.bbox[2], f.bbox[3], f.ascent, f.descent, f.capHeight, first+3),
+		fmt.Sprintf("<< /Length %d /Length1 %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), len(f.data), compressed.Bytes()),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(toUnicode), toUnicode),
+	}
+}
+
+func (u *fontUsage) toUnicode() string {
+	var b strings.Builder
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	b.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+
+	// В одном блоке bfchar допускается не больше 100 записей
+	gids := u.sortedGlyphs()
+	for len(gids) > 0 {
+		n := min(len(gids), 100)
+		fmt.Fprintf(&b, "%d beginbfchar\n", n)
+		for _, gid := range gids[:n] {
+			fmt.Fprintf(&b, "<%04X> <", uint16(gid))
+			for _, unit := range utf16.Encode([]rune{u.glyphs[gid]}) {
+				fmt.Fprintf(&b, "%04X", unit)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+		gids = gids[n:]
+	}
+
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.String()
+}
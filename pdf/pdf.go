@@ -0,0 +1,100 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Размер страницы A4 и поля в пунктах
+const (
+	PageWidth  = 595.0
+	PageHeight = 842.0
+	Margin     = 50.0
+)
+
+// Простой текстовый PDF-документ: строки текста шрифтами Go, встроенными
+// в документ, поэтому кириллица выводится без замен.
+type Document struct {
+	pages []*bytes.Buffer
+	fonts [2]*fontUsage
+	y     float64
+}
+
+// Число объектов, которые занимает один встроенный шрифт
+const fontObjects = 5
+
+func New() *Document {
+	d := &Document{fonts: [2]*fontUsage{newFontUsage(regularFont), newFontUsage(boldFont)}}
+	d.AddPage()
+	return d
+}
+
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = PageHeight - Margin
+}
+
+// Вывод текста в текущей строке с отступом x от левого края
+func (d *Document) Text(x, size float64, bold bool, text string) {
+	font, usage := "F1", d.fonts[0]
+	if bold {
+		font, usage = "F2", d.fonts[1]
+	}
+
+	page := d.pages[len(d.pages)-1]
+	fmt.Fprintf(page, "BT /%s %.1f Tf %.2f %.2f Td %s Tj ET\n", font, size, x, d.y, usage.encode(text))
+}
+
+// Переход на следующую строку высотой под шрифт size, с новой страницей при необходимости
+func (d *Document) Newline(size float64) {
+	d.y -= size * 1.5
+	if d.y < Margin {
+		d.AddPage()
+	}
+}
+
+// Сборка документа: каталог, дерево страниц, два шрифта и по странице с содержимым.
+// Шрифты записываются после страниц, когда известны все использованные глифы.
+func (d *Document) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Страница и её содержимое занимают по два объекта начиная с третьего
+	fontsFrom := 3 + len(d.pages)*2
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 3+i*2)
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+			PageWidth, PageHeight, fontsFrom, fontsFrom+fontObjects, 4+i*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	for i, usage := range d.fonts {
+		for _, body := range usage.objects(fontsFrom + i*fontObjects) {
+			object(body)
+		}
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
@@ -0,0 +1,110 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+var (
+	xrefEntry = regexp.MustCompile(`(\d{10}) 00000 n `)
+	textShow  = regexp.MustCompile(`/(F\d) [\d.]+ Tf [\d.]+ [\d.]+ Td <([0-9A-F]*)> Tj`)
+	bfchar    = regexp.MustCompile(`<([0-9A-F]{4})> <([0-9A-F]+)>`)
+	fontFile  = regexp.MustCompile(`(?s)<< /Length (\d+) /Length1 (\d+) /Filter /FlateDecode >>\nstream\n`)
+)
+
+// Текст, восстановленный по таблицам ToUnicode, совпадает с исходным, включая кириллицу
+func TestTextRoundTripsThroughToUnicode(t *testing.T) {
+	lines := []string{"Счёт 2026-0001", "Клиент: ООО «Ромашка»", "#12 Разработка (API) \\ тест"}
+
+	doc := New()
+	for i, line := range lines {
+		doc.Text(Margin, 10, i == 0, line)
+		doc.Newline(10)
+	}
+	data := doc.Bytes()
+
+	objects := parseObjects(t, data)
+
+	// Шрифты идут после страницы и её содержимого: F1 — объекты 5..9, F2 — 10..14
+	toUnicode := map[string]map[string]string{"F1": unicodeMap(objects[9]), "F2": unicodeMap(objects[14])}
+
+	shown := textShow.FindAllStringSubmatch(objects[4], -1)
+	if len(shown) != len(lines) {
+		t.Fatalf("got %d text operators, want %d", len(shown), len(lines))
+	}
+	for i, m := range shown {
+		var got strings.Builder
+		for j := 0; j+4 <= len(m[2]); j += 4 {
+			got.WriteString(toUnicode[m[1]][m[2][j:j+4]])
+		}
+		if got.String() != lines[i] {
+			t.Errorf("line %d = %q, want %q", i, got.String(), lines[i])
+		}
+	}
+}
+
+func TestFontIsEmbedded(t *testing.T) {
+	doc := New()
+	doc.Text(Margin, 10, false, "Привет")
+	data := doc.Bytes()
+
+	m := fontFile.FindSubmatchIndex(data)
+	if m == nil {
+		t.Fatal("no embedded font stream")
+	}
+	length, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+	r, err := zlib.NewReader(bytes.NewReader(data[m[1] : m[1]+length]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	font, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(font, goregular.TTF) {
+		t.Error("embedded font differs from goregular.TTF")
+	}
+}
+
+// Объекты документа по номерам; заодно проверяются смещения в таблице xref
+func parseObjects(t *testing.T, data []byte) map[int]string {
+	t.Helper()
+
+	start := bytes.LastIndex(data, []byte("\nxref\n"))
+	if start < 0 {
+		t.Fatal("no xref table")
+	}
+	objects := make(map[int]string)
+	for i, m := range xrefEntry.FindAllSubmatch(data[start:], -1) {
+		offset, _ := strconv.Atoi(string(m[1]))
+		header := fmt.Sprintf("%d 0 obj\n", i+1)
+		if !bytes.HasPrefix(data[offset:], []byte(header)) {
+			t.Fatalf("xref entry %d points to %q", i+1, data[offset:min(offset+20, len(data))])
+		}
+		end := bytes.Index(data[offset:], []byte("\nendobj\n"))
+		objects[i+1] = string(data[offset+len(header) : offset+end])
+	}
+	return objects
+}
+
+func unicodeMap(cmap string) map[string]string {
+	m := make(map[string]string)
+	for _, entry := range bfchar.FindAllStringSubmatch(cmap, -1) {
+		var units []uint16
+		for i := 0; i+4 <= len(entry[2]); i += 4 {
+			unit, _ := strconv.ParseUint(entry[2][i:i+4], 16, 16)
+			units = append(units, uint16(unit))
+		}
+		m[entry[1]] = string(utf16.Decode(units))
+	}
+	return m
+}
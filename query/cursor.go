@@ -0,0 +1,182 @@
+package query
+
+import (
+	"context"
+	"em-test/models"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Позиция в выборке при keyset-пагинации: значение поля сортировки и id последней записи.
+// Клиенту передаётся в виде непрозрачной строки.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    uint   `json:"i"`
+}
+
+func (cur Cursor) Encode() string {
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var cur Cursor
+	if err := json.Unmarshal(data, &cur); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	return &cur, nil
+}
+
+// Разбор параметров keyset-пагинации: допускается сортировка только по одному полю,
+// порядок дополняется id в том же направлении.
+func parseKeyset(values url.Values, spec Spec, params *Params) error {
+	params.Keyset = true
+
+	sortStr := values.Get("sort")
+	if sortStr == "" {
+		sortStr = spec.CursorSort
+	}
+	if sortStr == "" {
+		sortStr = "id"
+	}
+
+	desc := sortStr[0] == '-'
+	name := sortStr
+	if desc {
+		name = sortStr[1:]
+	}
+
+	field, ok := spec.Fields[name]
+	if !ok {
+		return fmt.Errorf("cursor pagination supports sorting by a single field, got: %s", sortStr)
+	}
+	params.Sort = []Order{{Name: name, Field: field, Desc: desc}}
+
+	if raw := values.Get("cursor"); raw != "" {
+		cur, err := decodeCursor(raw)
+		if err != nil {
+			return err
+		}
+		if cur.Sort != name || cur.Desc != desc {
+			return fmt.Errorf("cursor does not match sort order")
+		}
+
+		value, err := parseValue(field.Type, cur.Value)
+		if err != nil {
+			return fmt.Errorf("invalid cursor")
+		}
+		params.Cursor = cur
+		params.cursorValue = value
+	}
+
+	return nil
+}
+
+// Выборка страницы после курсора; возвращает курсор следующей страницы или nil
+func FindAfter[T any](db *gorm.DB, p *Params) ([]T, *Cursor, error) {
+	order := p.Sort[0]
+	column := order.Field.Column
+
+	direction, compare := "ASC", ">"
+	if order.Desc {
+		direction, compare = "DESC", "<"
+	}
+
+	db = p.Filter(db)
+	if p.Cursor != nil {
+		db = db.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, compare), p.cursorValue, p.Cursor.ID)
+	}
+
+	var items []T
+	err := db.Order(column + " " + direction).Order("id " + direction).Limit(p.PageSize + 1).Find(&items).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(items) <= p.PageSize {
+		return items, nil, nil
+	}
+	items = items[:p.PageSize]
+
+	next, err := cursorAfter(db, &items[len(items)-1], order)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return items, next, nil
+}
+
+// Формирование ответа с курсором и ссылкой на следующую страницу
+func NewCursorPage[T any](items []T, next *Cursor, p *Params, u *url.URL) models.CursorPage[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	page := models.CursorPage[T]{Items: items, PageSize: p.PageSize}
+
+	if next != nil {
+		encoded := next.Encode()
+
+		values := u.Query()
+		values.Set("cursor", encoded)
+		values.Set("page_size", strconv.Itoa(p.PageSize))
+		link := (&url.URL{Path: u.Path, RawQuery: values.Encode()}).String()
+
+		page.NextCursor = &encoded
+		page.Next = &link
+	}
+
+	return page
+}
+
+// Курсор, указывающий на запись item
+func cursorAfter(db *gorm.DB, item interface{}, order Order) (*Cursor, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(item); err != nil {
+		return nil, err
+	}
+
+	field := stmt.Schema.LookUpField(order.Field.Column)
+	primary := stmt.Schema.PrioritizedPrimaryField
+	if field == nil || primary == nil {
+		return nil, fmt.Errorf("cannot build cursor for column %s", order.Field.Column)
+	}
+
+	ctx := context.Background()
+	value := reflect.ValueOf(item).Elem()
+	fieldValue, _ := field.ValueOf(ctx, value)
+	id, _ := primary.ValueOf(ctx, value)
+
+	cur := &Cursor{Sort: order.Name, Desc: order.Desc}
+
+	switch v := fieldValue.(type) {
+	case time.Time:
+		cur.Value = v.Format(time.RFC3339Nano)
+	default:
+		cur.Value = fmt.Sprint(v)
+	}
+
+	switch v := id.(type) {
+	case uint:
+		cur.ID = v
+	default:
+		return nil, fmt.Errorf("unsupported primary key type %T", id)
+	}
+
+	return cur, nil
+}
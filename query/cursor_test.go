@@ -0,0 +1,151 @@
+package query
+
+import (
+	"encoding/base64"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type cursorItem struct {
+	ID        uint `gorm:"primaryKey"`
+	Rank      int
+	CreatedAt time.Time
+}
+
+var cursorSpec = Spec{
+	Fields: map[string]Field{
+		"id":         {Column: "id", Type: Int},
+		"rank":       {Column: "rank", Type: Int},
+		"created_at": {Column: "created_at", Type: Time},
+	},
+	CursorSort: "-created_at",
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursors := []Cursor{
+		{Sort: "rank", Value: "7", ID: 3},
+		{Sort: "created_at", Desc: true, Value: "2026-03-05T09:30:00.123456789Z", ID: 42},
+		{Sort: "name", Value: "Иванов, \"И.\"", ID: 1},
+	}
+
+	for _, cur := range cursors {
+		encoded := cur.Encode()
+		if strings.ContainsAny(encoded, "+/=") {
+			t.Errorf("cursor %q is not URL-safe", encoded)
+		}
+		decoded, err := decodeCursor(encoded)
+		if err != nil {
+			t.Errorf("decodeCursor(%q): %v", encoded, err)
+			continue
+		}
+		if *decoded != cur {
+			t.Errorf("decodeCursor(Encode(%v)) = %v", cur, *decoded)
+		}
+	}
+}
+
+func TestParseTamperedCursor(t *testing.T) {
+	encode := func(json string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(json))
+	}
+
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+		err    string
+	}{
+		{name: "not base64", sort: "rank", cursor: "!!!", err: "invalid cursor"},
+		{name: "not json", sort: "rank", cursor: encode("rank=7"), err: "invalid cursor"},
+		{name: "wrong types", sort: "rank", cursor: encode(`{"s":"rank","v":"7","i":"3"}`), err: "invalid cursor"},
+		{name: "bad value", sort: "rank", cursor: encode(`{"s":"rank","v":"seven","i":3}`), err: "invalid cursor"},
+		{name: "bad time", sort: "created_at", cursor: encode(`{"s":"created_at","v":"yesterday","i":3}`), err: "invalid cursor"},
+		{name: "other field", sort: "rank", cursor: (Cursor{Sort: "id", Value: "7", ID: 3}).Encode(), err: "cursor does not match sort order"},
+		{name: "other direction", sort: "-rank", cursor: (Cursor{Sort: "rank", Value: "7", ID: 3}).Encode(), err: "cursor does not match sort order"},
+		{name: "default sort", cursor: (Cursor{Sort: "created_at", Value: "2026-03-05T09:30:00Z", ID: 3}).Encode(), err: "cursor does not match sort order"},
+		{name: "several sort fields", sort: "rank,id", cursor: "", err: "cursor pagination supports sorting by a single field"},
+		{name: "unknown sort field", sort: "secret", cursor: "", err: "cursor pagination supports sorting by a single field"},
+	}
+
+	for _, tt := range tests {
+		values := url.Values{"cursor": {tt.cursor}}
+		if tt.sort != "" {
+			values.Set("sort", tt.sort)
+		}
+		_, err := Parse(values, cursorSpec)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: Parse error = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// Страницы не теряют и не повторяют записи с одинаковым значением поля сортировки
+func TestFindAfterWithDuplicateSortValues(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&cursorItem{}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC)
+	ranks := []int{2, 1, 2, 3, 2, 1, 2, 3, 2}
+	for i, rank := range ranks {
+		// У соседних пар записей одинаковое время создания
+		item := cursorItem{Rank: rank, CreatedAt: start.Add(time.Duration(i/2) * time.Minute)}
+		if err := db.Create(&item).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		sort string
+		want []uint
+	}{
+		{sort: "rank", want: []uint{2, 6, 1, 3, 5, 7, 9, 4, 8}},
+		{sort: "-rank", want: []uint{8, 4, 9, 7, 5, 3, 1, 6, 2}},
+		{sort: "", want: []uint{9, 8, 7, 6, 5, 4, 3, 2, 1}},
+		{sort: "created_at", want: []uint{1, 2, 3, 4, 5, 6, 7, 8, 9}},
+	}
+
+	for _, tt := range tests {
+		values := url.Values{"cursor": {""}, "page_size": {"2"}}
+		if tt.sort != "" {
+			values.Set("sort", tt.sort)
+		}
+
+		var ids []uint
+		for pages := 0; ; pages++ {
+			if pages > len(ranks) {
+				t.Fatalf("sort %q: paging does not stop", tt.sort)
+			}
+			params, err := Parse(values, cursorSpec)
+			if err != nil {
+				t.Fatalf("sort %q: Parse: %v", tt.sort, err)
+			}
+			items, next, err := FindAfter[cursorItem](db.Model(&cursorItem{}), params)
+			if err != nil {
+				t.Fatalf("sort %q: FindAfter: %v", tt.sort, err)
+			}
+			for _, item := range items {
+				ids = append(ids, item.ID)
+			}
+			if next == nil {
+				break
+			}
+			values.Set("cursor", next.Encode())
+		}
+
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("sort %q: paged ids = %v, want %v", tt.sort, ids, tt.want)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package query
+
+import (
+	"em-test/models"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
+)
+
+// Тип значения поля, определяет разбор значений фильтра
+type FieldType int
+
+const (
+	String FieldType = iota
+	Int
+	Time
+	Bool
+)
+
+// Оператор фильтра: field[op]=value, без [op] используется оператор поля по умолчанию
+type Operator string
+
+const (
+	Eq       Operator = "eq"
+	Ne       Operator = "ne"
+	Lt       Operator = "lt"
+	Gt       Operator = "gt"
+	In       Operator = "in"
+	Contains Operator = "contains"
+)
+
+// Поле, доступное для фильтрации и сортировки
+type Field struct {
+	Column    string
+	Type      FieldType
+	DefaultOp Operator
+}
+
+// Описание списка: поля по именам из JSON, сортировка по умолчанию
+// и сортировка по умолчанию для пагинации курсором
+type Spec struct {
+	Fields      map[string]Field
+	DefaultSort string
+	CursorSort  string
+}
+
+type Filter struct {
+	Field Field
+	Op    Operator
+	Value interface{}
+}
+
+type Order struct {
+	Name  string
+	Field Field
+	Desc  bool
+}
+
+// Разобранные параметры запроса списка.
+// Keyset выставляется, если передан параметр cursor (в том числе пустой для первой страницы).
+type Params struct {
+	Filters  []Filter
+	Sort     []Order
+	Page     int
+	PageSize int
+	Keyset   bool
+	Cursor   *Cursor
+
+	cursorValue interface{}
+}
+
+// Разбор параметров page, page_size, sort и фильтров по полям из spec.
+// Неизвестные параметры игнорируются, чтобы обработчики могли добавлять свои.
+func Parse(values url.Values, spec Spec) (*Params, error) {
+	params := &Params{Page: 1, PageSize: DefaultPageSize}
+
+	if pageStr := values.Get("page"); pageStr != "" {
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			return nil, fmt.Errorf("invalid page: %s", pageStr)
+		}
+		params.Page = page
+	}
+
+	if pageSizeStr := values.Get("page_size"); pageSizeStr != "" {
+		pageSize, err := strconv.Atoi(pageSizeStr)
+		if err != nil || pageSize < 1 {
+			return nil, fmt.Errorf("invalid page_size: %s", pageSizeStr)
+		}
+		params.PageSize = min(pageSize, MaxPageSize)
+	}
+
+	if values.Has("cursor") {
+		if err := parseKeyset(values, spec, params); err != nil {
+			return nil, err
+		}
+	} else if err := parseSort(values, spec, params); err != nil {
+		return nil, err
+	}
+
+	for key, vals := range values {
+		name, op := splitKey(key)
+
+		field, ok := spec.Fields[name]
+		if !ok {
+			continue
+		}
+		if op == "" {
+			op = field.DefaultOp
+		}
+		if op == "" {
+			op = Eq
+		}
+
+		for _, raw := range vals {
+			filter, err := parseFilter(field, op, raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter %s: %v", key, err)
+			}
+			params.Filters = append(params.Filters, filter)
+		}
+	}
+
+	return params, nil
+}
+
+// Разбор сортировки вида sort=-created_at,surname
+func parseSort(values url.Values, spec Spec, params *Params) error {
+	sortStr := values.Get("sort")
+	if sortStr == "" {
+		sortStr = spec.DefaultSort
+	}
+
+	for _, name := range strings.Split(sortStr, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+
+		field, ok := spec.Fields[name]
+		if !ok {
+			return fmt.Errorf("unknown sort field: %s", name)
+		}
+		params.Sort = append(params.Sort, Order{Name: name, Field: field, Desc: desc})
+	}
+
+	return nil
+}
+
+// Применение фильтров к запросу
+func (p *Params) Filter(db *gorm.DB) *gorm.DB {
+	for _, filter := range p.Filters {
+		column := filter.Field.Column
+
+		switch filter.Op {
+		case Eq:
+			db = db.Where(column+" = ?", filter.Value)
+		case Ne:
+			db = db.Where(column+" <> ?", filter.Value)
+		case Lt:
+			db = db.Where(column+" < ?", filter.Value)
+		case Gt:
+			db = db.Where(column+" > ?", filter.Value)
+		case In:
+			db = db.Where(column+" IN ?", filter.Value)
+		case Contains:
+			db = db.Where(column+" ILIKE ?", "%"+escapeLike(filter.Value.(string))+"%")
+		}
+	}
+
+	return db
+}
+
+// Применение сортировки; id добавляется последним для стабильного порядка страниц
+func (p *Params) Order(db *gorm.DB) *gorm.DB {
+	for _, order := range p.Sort {
+		if order.Desc {
+			db = db.Order(order.Field.Column + " DESC")
+		} else {
+			db = db.Order(order.Field.Column)
+		}
+	}
+
+	return db.Order("id")
+}
+
+// Подсчёт общего числа записей и выборка текущей страницы
+func (p *Params) Find(db *gorm.DB, dest interface{}) (int64, error) {
+	var total int64
+
+	db = p.Filter(db)
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	offset := (p.Page - 1) * p.PageSize
+	if err := p.Order(db).Limit(p.PageSize).Offset(offset).Find(dest).Error; err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// Формирование ответа со ссылками на соседние страницы
+func NewPage[T any](items []T, total int64, p *Params, u *url.URL) models.Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	page := models.Page[T]{
+		Items:    items,
+		Total:    total,
+		Page:     p.Page,
+		PageSize: p.PageSize,
+	}
+
+	if int64(p.Page*p.PageSize) < total {
+		next := pageLink(u, p.Page+1, p.PageSize)
+		page.Next = &next
+	}
+	if p.Page > 1 {
+		prev := pageLink(u, p.Page-1, p.PageSize)
+		page.Prev = &prev
+	}
+
+	return page
+}
+
+func pageLink(u *url.URL, page, pageSize int) string {
+	values := u.Query()
+	values.Set("page", strconv.Itoa(page))
+	values.Set("page_size", strconv.Itoa(pageSize))
+
+	link := url.URL{Path: u.Path, RawQuery: values.Encode()}
+	return link.String()
+}
+
+// Разделение ключа вида field[op] на имя поля и оператор
+func splitKey(key string) (string, Operator) {
+	open := strings.IndexByte(key, '[')
+	if open < 0 || !strings.HasSuffix(key, "]") {
+		return key, ""
+	}
+
+	return key[:open], Operator(key[open+1 : len(key)-1])
+}
+
+func parseFilter(field Field, op Operator, raw string) (Filter, error) {
+	filter := Filter{Field: field, Op: op}
+
+	switch op {
+	case Eq, Ne, Lt, Gt:
+		value, err := parseValue(field.Type, raw)
+		if err != nil {
+			return filter, err
+		}
+		filter.Value = value
+	case In:
+		parts := strings.Split(raw, ",")
+		values := make([]interface{}, 0, len(parts))
+		for _, part := range parts {
+			value, err := parseValue(field.Type, strings.TrimSpace(part))
+			if err != nil {
+				return filter, err
+			}
+			values = append(values, value)
+		}
+		filter.Value = values
+	case Contains:
+		if field.Type != String {
+			return filter, fmt.Errorf("contains is only supported for text fields")
+		}
+		filter.Value = raw
+	default:
+		return filter, fmt.Errorf("unknown operator %s", op)
+	}
+
+	return filter, nil
+}
+
+func parseValue(fieldType FieldType, raw string) (interface{}, error) {
+	switch fieldType {
+	case Int:
+		return strconv.ParseInt(raw, 10, 64)
+	case Bool:
+		return strconv.ParseBool(raw)
+	case Time:
+		return ParseTime(raw)
+	default:
+		return raw, nil
+	}
+}
+
+// Разбор времени в формате RFC3339 или даты YYYY-MM-DD
+func ParseTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
@@ -0,0 +1,151 @@
+package query
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSpec = Spec{
+	Fields: map[string]Field{
+		"id":         {Column: "id", Type: Int},
+		"name":       {Column: "name", Type: String, DefaultOp: Contains},
+		"status":     {Column: "status", Type: String},
+		"active":     {Column: "active", Type: Bool},
+		"created_at": {Column: "created_at", Type: Time},
+	},
+	DefaultSort: "-created_at",
+}
+
+func TestParseFilters(t *testing.T) {
+	day := time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)
+	moment := time.Date(2026, 3, 5, 9, 30, 0, 0, time.FixedZone("", 3*3600))
+
+	tests := []struct {
+		query string
+		want  []Filter
+	}{
+		{query: "id=5", want: []Filter{{Field: testSpec.Fields["id"], Op: Eq, Value: int64(5)}}},
+		{query: "id[eq]=5", want: []Filter{{Field: testSpec.Fields["id"], Op: Eq, Value: int64(5)}}},
+		{query: "id[ne]=5", want: []Filter{{Field: testSpec.Fields["id"], Op: Ne, Value: int64(5)}}},
+		{query: "id[lt]=5", want: []Filter{{Field: testSpec.Fields["id"], Op: Lt, Value: int64(5)}}},
+		{query: "id[gt]=-5", want: []Filter{{Field: testSpec.Fields["id"], Op: Gt, Value: int64(-5)}}},
+		{query: "id[in]=1,%202", want: []Filter{{Field: testSpec.Fields["id"], Op: In, Value: []interface{}{int64(1), int64(2)}}}},
+		{query: "id=1&id=2", want: []Filter{
+			{Field: testSpec.Fields["id"], Op: Eq, Value: int64(1)},
+			{Field: testSpec.Fields["id"], Op: Eq, Value: int64(2)},
+		}},
+		{query: "name=an", want: []Filter{{Field: testSpec.Fields["name"], Op: Contains, Value: "an"}}},
+		{query: "name[eq]=Ann", want: []Filter{{Field: testSpec.Fields["name"], Op: Eq, Value: "Ann"}}},
+		{query: "status=open", want: []Filter{{Field: testSpec.Fields["status"], Op: Eq, Value: "open"}}},
+		{query: "status[contains]=op", want: []Filter{{Field: testSpec.Fields["status"], Op: Contains, Value: "op"}}},
+		{query: "status[in]=open,closed", want: []Filter{{Field: testSpec.Fields["status"], Op: In, Value: []interface{}{"open", "closed"}}}},
+		{query: "active=true", want: []Filter{{Field: testSpec.Fields["active"], Op: Eq, Value: true}}},
+		{query: "active[ne]=0", want: []Filter{{Field: testSpec.Fields["active"], Op: Ne, Value: false}}},
+		{query: "created_at[gt]=2026-03-05", want: []Filter{{Field: testSpec.Fields["created_at"], Op: Gt, Value: day}}},
+		{query: "created_at[lt]=2026-03-05T09:30:00%2B03:00", want: []Filter{{Field: testSpec.Fields["created_at"], Op: Lt, Value: moment}}},
+		{query: "unknown=1&page=2&tag=x"},
+		{query: "unknown[like]=1"},
+	}
+
+	for _, tt := range tests {
+		values, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params, err := Parse(values, testSpec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(params.Filters, tt.want) {
+			t.Errorf("Parse(%q) filters = %#v, want %#v", tt.query, params.Filters, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{query: "id=abc", err: "invalid filter id"},
+		{query: "id[in]=1,x", err: "invalid filter id[in]"},
+		{query: "id[contains]=1", err: "contains is only supported for text fields"},
+		{query: "id[like]=1", err: "unknown operator like"},
+		{query: "active=maybe", err: "invalid filter active"},
+		{query: "created_at=yesterday", err: "invalid filter created_at"},
+		{query: "created_at[gt]=2026-13-01", err: "invalid filter created_at[gt]"},
+		{query: "page=0", err: "invalid page: 0"},
+		{query: "page=x", err: "invalid page: x"},
+		{query: "page_size=0", err: "invalid page_size: 0"},
+		{query: "page_size=-1", err: "invalid page_size: -1"},
+		{query: "sort=password", err: "unknown sort field: password"},
+		{query: "sort=name,-secret", err: "unknown sort field: secret"},
+	}
+
+	for _, tt := range tests {
+		values, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = Parse(values, testSpec)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.query, err, tt.err)
+		}
+	}
+}
+
+func TestParsePaging(t *testing.T) {
+	tests := []struct {
+		query    string
+		page     int
+		pageSize int
+	}{
+		{query: "", page: 1, pageSize: DefaultPageSize},
+		{query: "page=3&page_size=25", page: 3, pageSize: 25},
+		{query: "page_size=100", page: 1, pageSize: 100},
+		{query: "page_size=101", page: 1, pageSize: MaxPageSize},
+		{query: "page_size=100000", page: 1, pageSize: MaxPageSize},
+	}
+
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		params, err := Parse(values, testSpec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.query, err)
+			continue
+		}
+		if params.Page != tt.page || params.PageSize != tt.pageSize {
+			t.Errorf("Parse(%q) page = %d, page_size = %d, want %d, %d", tt.query, params.Page, params.PageSize, tt.page, tt.pageSize)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []Order
+	}{
+		{query: "", want: []Order{{Name: "created_at", Field: testSpec.Fields["created_at"], Desc: true}}},
+		{query: "sort=name", want: []Order{{Name: "name", Field: testSpec.Fields["name"]}}},
+		{query: "sort=-status,%20id,", want: []Order{
+			{Name: "status", Field: testSpec.Fields["status"], Desc: true},
+			{Name: "id", Field: testSpec.Fields["id"]},
+		}},
+	}
+
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		params, err := Parse(values, testSpec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(params.Sort, tt.want) {
+			t.Errorf("Parse(%q) sort = %v, want %v", tt.query, params.Sort, tt.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package recurrence
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Горизонт поиска следующего срабатывания; правила вроде "30 февраля" не срабатывают никогда
+const searchYears = 5
+
+// Сокращения для часто используемых правил
+var macros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 1",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+type field struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: monthNames},
+	{name: "day of week", min: 0, max: 7, names: dayNames},
+}
+
+// Правило повторения в формате cron: "минута час день месяц день_недели".
+// Поддерживаются *, списки, диапазоны, шаги, имена месяцев и дней недели и макросы @daily и т.п.
+type Rule struct {
+	minute, hour, dom, month, dow uint64
+
+	// Как в cron: если ограничены и день месяца, и день недели, достаточно совпадения любого
+	domAny, dowAny bool
+}
+
+func Parse(expr string) (*Rule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := macros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("expected %d fields, got %d", len(fields), len(parts))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, part := range parts {
+		b, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, err
+		}
+		bits[i] = b
+	}
+
+	// Воскресенье можно задать как 0 или 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return &Rule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: strings.HasPrefix(parts[2], "*"),
+		dowAny: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+func parseField(expr string, f field) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, step := part, 1
+		if slash := strings.IndexByte(part, '/'); slash >= 0 {
+			n, err := strconv.Atoi(part[slash+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in %s: %q", f.name, part)
+			}
+			rangeExpr, step = part[:slash], n
+		}
+
+		from, to := f.min, f.max
+		switch {
+		case rangeExpr == "*":
+		case strings.Contains(rangeExpr, "-"):
+			bounds := strings.SplitN(rangeExpr, "-", 2)
+			var err error
+			if from, err = parseValue(bounds[0], f); err != nil {
+				return 0, err
+			}
+			if to, err = parseValue(bounds[1], f); err != nil {
+				return 0, err
+			}
+			if from > to {
+				return 0, fmt.Errorf("invalid range in %s: %q", f.name, part)
+			}
+		default:
+			value, err := parseValue(rangeExpr, f)
+			if err != nil {
+				return 0, err
+			}
+			from = value
+			// "5/15" означает с 5 до конца диапазона с шагом 15
+			if step == 1 {
+				to = value
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << v
+		}
+	}
+
+	return bits, nil
+}
+
+func parseValue(raw string, f field) (int, error) {
+	if v, ok := f.names[strings.ToLower(raw)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s: %q", f.name, raw)
+	}
+	return v, nil
+}
+
+// Ближайшее срабатывание строго после after (с точностью до минуты) в часовом поясе after.
+// Нулевое время, если правило не срабатывает в ближайшие годы.
+// При переходе на летнее время срабатывания в пропущенный час не происходят,
+// а при переходе на зимнее повторённый час срабатывает один раз.
+func (r *Rule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.AddDate(searchYears, 0, 0)
+	passed := wallClock(after)
+
+	for t.Before(limit) {
+		if r.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !r.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if r.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if r.minute&(1<<uint(t.Minute())) == 0 || !wallClock(t).After(passed) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// Ближайшие count срабатываний после after
+func (r *Rule) Upcoming(after time.Time, count int) []time.Time {
+	times := make([]time.Time, 0, count)
+
+	for len(times) < count {
+		next := r.Next(after)
+		if next.IsZero() {
+			break
+		}
+		times = append(times, next)
+		after = next
+	}
+
+	return times
+}
+
+// Время по часам без учёта часового пояса
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+func (r *Rule) dayMatches(t time.Time) bool {
+	domMatch := r.dom&(1<<uint(t.Day())) != 0
+	dowMatch := r.dow&(1<<uint(t.Weekday())) != 0
+
+	if r.domAny || r.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,100 @@
+package recurrence
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{expr: "", err: "expected 5 fields, got 0"},
+		{expr: "0 9 * *", err: "expected 5 fields, got 4"},
+		{expr: "0 9 * * 1 2026", err: "expected 5 fields, got 6"},
+		{expr: "@fortnightly", err: "expected 5 fields, got 1"},
+		{expr: "60 * * * *", err: `invalid minute: "60"`},
+		{expr: "* 24 * * *", err: `invalid hour: "24"`},
+		{expr: "* * 0 * *", err: `invalid day of month: "0"`},
+		{expr: "* * 32 * *", err: `invalid day of month: "32"`},
+		{expr: "* * * 13 *", err: `invalid month: "13"`},
+		{expr: "* * * foo *", err: `invalid month: "foo"`},
+		{expr: "* * * * 8", err: `invalid day of week: "8"`},
+		{expr: "* * * * sunday", err: `invalid day of week: "sunday"`},
+		{expr: "*/0 * * * *", err: `invalid step in minute: "*/0"`},
+		{expr: "*/x * * * *", err: `invalid step in minute: "*/x"`},
+		{expr: "30-10 * * * *", err: `invalid range in minute: "30-10"`},
+		{expr: "10- * * * *", err: `invalid minute: ""`},
+		{expr: "1,,2 * * * *", err: `invalid minute: ""`},
+		{expr: "-5 * * * *", err: `invalid minute: ""`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.expr, err, tt.err)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	local := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, berlin)
+	}
+
+	tests := []struct {
+		name  string
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{name: "later the same hour", expr: "30 9 * * *", after: utc(2026, 3, 5, 9, 10), want: utc(2026, 3, 5, 9, 30)},
+		{name: "strictly after", expr: "30 9 * * *", after: utc(2026, 3, 5, 9, 30), want: utc(2026, 3, 6, 9, 30)},
+		{name: "seconds are truncated", expr: "* * * * *", after: utc(2026, 3, 5, 9, 30).Add(45 * time.Second), want: utc(2026, 3, 5, 9, 31)},
+		{name: "steps from a value", expr: "5/20 * * * *", after: utc(2026, 3, 5, 9, 26), want: utc(2026, 3, 5, 9, 45)},
+		{name: "macro", expr: "@monthly", after: utc(2026, 3, 5, 9, 0), want: utc(2026, 4, 1, 0, 0)},
+		{name: "sunday as 7", expr: "0 0 * * 7", after: utc(2026, 3, 5, 0, 0), want: utc(2026, 3, 8, 0, 0)},
+		{name: "day names", expr: "0 9 * * mon-fri", after: utc(2026, 3, 6, 10, 0), want: utc(2026, 3, 9, 9, 0)},
+
+		{name: "to the next month", expr: "0 0 1 * *", after: utc(2026, 1, 31, 12, 0), want: utc(2026, 2, 1, 0, 0)},
+		{name: "to the next year", expr: "0 0 1 * *", after: utc(2026, 12, 15, 0, 0), want: utc(2027, 1, 1, 0, 0)},
+		{name: "31st skips short months", expr: "0 0 31 * *", after: utc(2026, 3, 31, 12, 0), want: utc(2026, 5, 31, 0, 0)},
+		{name: "30th skips february", expr: "0 0 30 * *", after: utc(2026, 1, 30, 12, 0), want: utc(2026, 3, 30, 0, 0)},
+		{name: "29 february in a leap year", expr: "0 0 29 2 *", after: utc(2026, 3, 1, 0, 0), want: utc(2028, 2, 29, 0, 0)},
+		{name: "never", expr: "0 0 30 2 *", after: utc(2026, 3, 1, 0, 0)},
+
+		{name: "day of month or day of week", expr: "0 0 13 * fri", after: utc(2026, 3, 1, 0, 0), want: utc(2026, 3, 6, 0, 0)},
+		{name: "day of month before day of week", expr: "0 0 13 * fri", after: utc(2026, 3, 6, 0, 0), want: utc(2026, 3, 13, 0, 0)},
+		{name: "restricted day of week with any day of month", expr: "0 0 * * fri", after: utc(2026, 3, 6, 0, 0), want: utc(2026, 3, 13, 0, 0)},
+		{name: "stepped day of month counts as any", expr: "0 0 */2 * fri", after: utc(2026, 3, 1, 0, 0), want: utc(2026, 3, 13, 0, 0)},
+
+		{name: "local time zone", expr: "0 9 * * *", after: local(2026, 3, 5, 10, 0), want: local(2026, 3, 6, 9, 0)},
+		{name: "across the spring change", expr: "0 9 * * *", after: local(2026, 3, 28, 10, 0), want: local(2026, 3, 29, 9, 0)},
+		{name: "skipped hour", expr: "30 2 * * *", after: local(2026, 3, 29, 0, 0), want: local(2026, 3, 30, 2, 30)},
+		{name: "hour after the skipped one", expr: "0 3 * * *", after: local(2026, 3, 29, 0, 0), want: local(2026, 3, 29, 3, 0)},
+		{name: "across the autumn change", expr: "0 9 * * *", after: local(2026, 10, 24, 10, 0), want: local(2026, 10, 25, 9, 0)},
+		{name: "repeated hour fires once", expr: "30 2 * * *", after: utc(2026, 10, 25, 0, 30).In(berlin), want: local(2026, 10, 26, 2, 30)},
+		{name: "hourly in the repeated hour", expr: "@hourly", after: utc(2026, 10, 25, 0, 0).In(berlin), want: local(2026, 10, 25, 3, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			if got := rule.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.after, got, tt.want)
+			}
+		})
+	}
+}
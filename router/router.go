@@ -26,12 +26,171 @@ func SetupRouter(validate *validator.Validate) *gin.Engine {
 		controllers.UpdateUserHandler(c, validate)
 	})
 	router.DELETE("/users/:id", controllers.DeleteUserHandler)
+	router.GET("/users/:id/current-timer", controllers.GetCurrentTimerHandler)
+	router.GET("/users/:id/tasks", controllers.GetUserTasksHandler)
 
 	router.GET("/tasks", controllers.GetTasksHandler)
+	router.GET("/tasks/workflow", controllers.GetWorkflowHandler)
+	router.GET("/tasks/estimates", controllers.GetTaskEstimatesHandler)
+	router.GET("/tasks/over-budget", controllers.GetTasksOverBudgetHandler)
 	router.GET("/tasks/:id", controllers.GetTaskHandler)
 	router.POST("/tasks", func(c *gin.Context) {
 		controllers.CreateTaskHandler(c, validate)
 	})
+	router.PUT("/tasks/:id", func(c *gin.Context) {
+		controllers.UpdateTaskHandler(c, validate)
+	})
+	router.GET("/tasks/:id/transitions", controllers.GetTaskTransitionsHandler)
+	router.POST("/tasks/:id/transitions", func(c *gin.Context) {
+		controllers.TransitionTaskHandler(c, validate)
+	})
+	router.GET("/tasks/:id/assignees", controllers.GetTaskAssigneesHandler)
+	router.POST("/tasks/:id/assignees", func(c *gin.Context) {
+		controllers.AddTaskAssigneesHandler(c, validate)
+	})
+	router.DELETE("/tasks/:id/assignees/:user_id", controllers.RemoveTaskAssigneeHandler)
+	router.GET("/tasks/:id/dependencies", controllers.GetTaskDependenciesHandler)
+	router.POST("/tasks/:id/dependencies", func(c *gin.Context) {
+		controllers.AddTaskDependencyHandler(c, validate)
+	})
+	router.DELETE("/tasks/:id/dependencies/:blocked_by_id", controllers.RemoveTaskDependencyHandler)
+
+	router.GET("/tasks/:id/comments", controllers.GetTaskCommentsHandler)
+	router.POST("/tasks/:id/comments", func(c *gin.Context) {
+		controllers.CreateCommentHandler(c, validate)
+	})
+	router.PUT("/comments/:id", func(c *gin.Context) {
+		controllers.UpdateCommentHandler(c, validate)
+	})
+	router.DELETE("/comments/:id", controllers.DeleteCommentHandler)
+	router.GET("/users/:id/mentions", controllers.GetUserMentionsHandler)
+
+	router.GET("/tasks/:id/attachments", controllers.GetTaskAttachmentsHandler)
+	router.POST("/tasks/:id/attachments", controllers.UploadAttachmentHandler)
+	router.GET("/attachments/:id", controllers.GetAttachmentHandler)
+	router.GET("/attachments/:id/content", controllers.DownloadAttachmentHandler)
+	router.DELETE("/attachments/:id", controllers.DeleteAttachmentHandler)
+
+	router.GET("/rates", controllers.GetRatesHandler)
+	router.GET("/rates/:id", controllers.GetRateHandler)
+	router.POST("/rates", func(c *gin.Context) {
+		controllers.CreateRateHandler(c, validate)
+	})
+	router.PUT("/rates/:id", func(c *gin.Context) {
+		controllers.UpdateRateHandler(c, validate)
+	})
+	router.DELETE("/rates/:id", controllers.DeleteRateHandler)
+
+	router.GET("/invoices", controllers.GetInvoicesHandler)
+	router.GET("/invoices/:id", controllers.GetInvoiceHandler)
+	router.GET("/invoices/:id/pdf", controllers.GetInvoicePDFHandler)
+	router.POST("/invoices", func(c *gin.Context) {
+		controllers.CreateInvoiceHandler(c, validate)
+	})
+	router.POST("/invoices/:id/void", controllers.VoidInvoiceHandler)
+
+	router.GET("/timesheets", controllers.GetTimesheetsHandler)
+	router.GET("/timesheets/:id", controllers.GetTimesheetHandler)
+	router.POST("/timesheets", func(c *gin.Context) {
+		controllers.CreateTimesheetHandler(c, validate)
+	})
+	router.POST("/timesheets/:id/submit", func(c *gin.Context) {
+		controllers.SubmitTimesheetHandler(c, validate)
+	})
+	router.POST("/timesheets/:id/approve", func(c *gin.Context) {
+		controllers.ApproveTimesheetHandler(c, validate)
+	})
+	router.POST("/timesheets/:id/reject", func(c *gin.Context) {
+		controllers.RejectTimesheetHandler(c, validate)
+	})
+
+	router.GET("/period-locks", controllers.GetPeriodLocksHandler)
+	router.GET("/period-locks/:id", controllers.GetPeriodLockHandler)
+	router.POST("/period-locks", func(c *gin.Context) {
+		controllers.CreatePeriodLockHandler(c, validate)
+	})
+	router.PUT("/period-locks/:id", func(c *gin.Context) {
+		controllers.UpdatePeriodLockHandler(c, validate)
+	})
+	router.DELETE("/period-locks/:id", controllers.DeletePeriodLockHandler)
+
+	router.GET("/rounding-policies", controllers.GetRoundingPoliciesHandler)
+	router.GET("/rounding-policies/:id", controllers.GetRoundingPolicyHandler)
+	router.POST("/rounding-policies", func(c *gin.Context) {
+		controllers.CreateRoundingPolicyHandler(c, validate)
+	})
+	router.PUT("/rounding-policies/:id", func(c *gin.Context) {
+		controllers.UpdateRoundingPolicyHandler(c, validate)
+	})
+	router.DELETE("/rounding-policies/:id", controllers.DeleteRoundingPolicyHandler)
+
+	router.GET("/users/:id/schedule", controllers.GetWorkScheduleHandler)
+	router.PUT("/users/:id/schedule", func(c *gin.Context) {
+		controllers.SetWorkScheduleHandler(c, validate)
+	})
+	router.GET("/holidays", controllers.GetHolidaysHandler)
+	router.POST("/holidays", func(c *gin.Context) {
+		controllers.CreateHolidayHandler(c, validate)
+	})
+	router.POST("/holidays/import", controllers.ImportHolidaysHandler)
+	router.DELETE("/holidays/:id", controllers.DeleteHolidayHandler)
+	router.GET("/absences", controllers.GetAbsencesHandler)
+	router.GET("/absences/:id", controllers.GetAbsenceHandler)
+	router.POST("/absences", func(c *gin.Context) {
+		controllers.CreateAbsenceHandler(c, validate)
+	})
+	router.PUT("/absences/:id", func(c *gin.Context) {
+		controllers.UpdateAbsenceHandler(c, validate)
+	})
+	router.DELETE("/absences/:id", controllers.DeleteAbsenceHandler)
+	router.GET("/capacity", controllers.GetCapacityHandler)
+
+	router.GET("/templates", controllers.GetTemplatesHandler)
+	router.GET("/templates/:id", controllers.GetTemplateHandler)
+	router.POST("/templates", func(c *gin.Context) {
+		controllers.CreateTemplateHandler(c, validate)
+	})
+	router.PUT("/templates/:id", func(c *gin.Context) {
+		controllers.UpdateTemplateHandler(c, validate)
+	})
+	router.DELETE("/templates/:id", controllers.DeleteTemplateHandler)
+	router.GET("/templates/:id/occurrences", controllers.GetTemplateOccurrencesHandler)
+
+	router.GET("/clients", controllers.GetClientsHandler)
+	router.GET("/clients/:id", controllers.GetClientHandler)
+	router.POST("/clients", func(c *gin.Context) {
+		controllers.CreateClientHandler(c, validate)
+	})
+	router.PUT("/clients/:id", func(c *gin.Context) {
+		controllers.UpdateClientHandler(c, validate)
+	})
+	router.DELETE("/clients/:id", controllers.DeleteClientHandler)
+
+	router.GET("/projects", controllers.GetProjectsHandler)
+	router.GET("/projects/:id", controllers.GetProjectHandler)
+	router.POST("/projects", func(c *gin.Context) {
+		controllers.CreateProjectHandler(c, validate)
+	})
+	router.PUT("/projects/:id", func(c *gin.Context) {
+		controllers.UpdateProjectHandler(c, validate)
+	})
+	router.DELETE("/projects/:id", controllers.DeleteProjectHandler)
+
+	router.GET("/webhooks", controllers.GetWebhooksHandler)
+	router.GET("/webhooks/:id", controllers.GetWebhookHandler)
+	router.POST("/webhooks", func(c *gin.Context) {
+		controllers.CreateWebhookHandler(c, validate)
+	})
+	router.PUT("/webhooks/:id", func(c *gin.Context) {
+		controllers.UpdateWebhookHandler(c, validate)
+	})
+	router.DELETE("/webhooks/:id", controllers.DeleteWebhookHandler)
+	router.POST("/webhooks/:id/test", controllers.TestWebhookHandler)
+	router.GET("/webhook-deliveries", controllers.GetWebhookDeliveriesHandler)
+	router.POST("/webhook-deliveries/:id/retry", controllers.RetryWebhookDeliveryHandler)
+
+	router.GET("/events", controllers.StreamEventsHandler)
+	router.GET("/events/ws", controllers.EventsWebSocketHandler)
 
 	router.GET("/tasklogs", controllers.GetTaskLogsHandler)
 	router.GET("/tasklogs/:id", controllers.GetTaskLogHandler)
@@ -39,8 +198,20 @@ func SetupRouter(validate *validator.Validate) *gin.Engine {
 		controllers.CreateAndStartTaskLog(c, validate)
 	})
 	router.PUT("/tasklogs/:id/complete", controllers.CompleteTaskLogHandler)
+	router.PUT("/tasklogs/:id/acknowledge", controllers.AcknowledgeTaskLogHandler)
+	router.PUT("/tasklogs/:id/tags", func(c *gin.Context) {
+		controllers.SetTaskLogTagsHandler(c, validate)
+	})
+	router.PUT("/tasklogs/:id/billable", func(c *gin.Context) {
+		controllers.SetTaskLogBillableHandler(c, validate)
+	})
+
+	router.GET("/tags", controllers.GetTagsHandler)
 
 	router.GET("/tasktimes", controllers.GetUserTaskTimes)
+	router.GET("/workload", controllers.GetWorkloadHandler)
+
+	router.GET("/search", controllers.SearchHandler)
 
 	swaggerAddress := fmt.Sprintf("%s/swagger/doc.json", serviceAddress)
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL(swaggerAddress)))
@@ -0,0 +1,22 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+)
+
+// Периодический запуск фоновой задачи. Первый запуск выполняется сразу,
+// ошибки только логируются: следующий запуск повторит работу.
+func Every(interval time.Duration, name string, job func(now time.Time) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := job(time.Now().UTC()); err != nil {
+				log.Printf("scheduler: %s failed: %v", name, err)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,106 @@
+package search
+
+import (
+	"em-test/models"
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// Конфигурация ru_en основана на russian: кириллица обрабатывается русским стеммером,
+// латиница — английским
+const textSearchConfig = "ru_en"
+
+const taskDocument = `setweight(to_tsvector('ru_en', coalesce(title, '')), 'A') ||
+	setweight(to_tsvector('ru_en', coalesce(description, '')), 'B')`
+
+const userDocument = `setweight(to_tsvector('ru_en', coalesce(surname, '') || ' ' || coalesce(name, '') || ' ' || coalesce(patronymic, '')), 'A') ||
+	setweight(to_tsvector('ru_en', coalesce(address, '')), 'B')`
+
+const headlineOptions = "StartSel=" + matchStart + ", StopSel=" + matchStop + ", MaxFragments=2, MaxWords=20, MinWords=5"
+
+// Текст для ts_headline без символов, совпадающих с маркерами подсветки
+func headlineText(expr string) string {
+	return "translate(" + expr + ", chr(2) || chr(3), '')"
+}
+
+type postgresSearcher struct {
+	db *gorm.DB
+}
+
+func migratePostgres(db *gorm.DB) error {
+	statements := []string{
+		`DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = '` + textSearchConfig + `') THEN
+				CREATE TEXT SEARCH CONFIGURATION ` + textSearchConfig + ` (COPY = russian);
+				ALTER TEXT SEARCH CONFIGURATION ` + textSearchConfig + `
+					ALTER MAPPING FOR asciiword, asciihword, hword_asciipart WITH english_stem;
+			END IF;
+		END
+		$$`,
+		"CREATE INDEX IF NOT EXISTS idx_tasks_search ON tasks USING GIN ((" + taskDocument + "))",
+		"CREATE INDEX IF NOT EXISTS idx_users_search ON users USING GIN ((" + userDocument + "))",
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *postgresSearcher) Search(q string, types []string, limit int) ([]models.SearchResult, error) {
+	results := []models.SearchResult{}
+
+	if wants(types, TypeTask) {
+		var tasks []models.SearchResult
+		err := s.db.Raw(fmt.Sprintf(`SELECT '%s' AS type, id,
+				ts_headline('%s', %s, query, '%s') AS title,
+				ts_headline('%s', %s, query, '%s') AS snippet,
+				ts_rank(%s, query) AS rank
+			FROM tasks, websearch_to_tsquery('%s', ?) query
+			WHERE (%s) @@ query
+			ORDER BY rank DESC, id
+			LIMIT ?`, TypeTask, textSearchConfig, headlineText("title"), headlineOptions, textSearchConfig, headlineText("description"), headlineOptions, taskDocument, textSearchConfig, taskDocument), q, limit).
+			Scan(&tasks).Error
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, tasks...)
+	}
+
+	if wants(types, TypeUser) {
+		var users []models.SearchResult
+		err := s.db.Raw(fmt.Sprintf(`SELECT '%s' AS type, id,
+				ts_headline('%s', %s, query, '%s') AS title,
+				ts_headline('%s', %s, query, '%s') AS snippet,
+				ts_rank(%s, query) AS rank
+			FROM users, websearch_to_tsquery('%s', ?) query
+			WHERE (%s) @@ query
+			ORDER BY rank DESC, id
+			LIMIT ?`, TypeUser, textSearchConfig, headlineText("surname || ' ' || name || ' ' || patronymic"), headlineOptions, textSearchConfig, headlineText("address"), headlineOptions, userDocument, textSearchConfig, userDocument), q, limit).
+			Scan(&users).Error
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, users...)
+	}
+
+	for i := range results {
+		results[i].Title = markup(results[i].Title)
+		results[i].Snippet = markup(results[i].Snippet)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Rank > results[j].Rank
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
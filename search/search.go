@@ -0,0 +1,60 @@
+package search
+
+import (
+	"em-test/models"
+	"html"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Типы документов, по которым выполняется поиск
+const (
+	TypeTask = "task"
+	TypeUser = "user"
+)
+
+// Маркеры подсветки найденных слов в сниппете. Остальной текст сниппета
+// экранирован, поэтому его можно выводить как HTML.
+const (
+	HighlightStart = "<b>"
+	HighlightStop  = "</b>"
+)
+
+// Нейтральные маркеры, которыми БД отмечает совпадения до экранирования текста.
+// Из самого текста они удаляются до подсветки, иначе пользователь мог бы вставить теги.
+const (
+	matchStart = "\x02"
+	matchStop  = "\x03"
+)
+
+// Экранирование текста с заменой нейтральных маркеров на теги подсветки
+func markup(text string) string {
+	return strings.NewReplacer(matchStart, HighlightStart, matchStop, HighlightStop).Replace(html.EscapeString(text))
+}
+
+type Searcher interface {
+	Search(q string, types []string, limit int) ([]models.SearchResult, error)
+}
+
+// Поиск использует полнотекстовые возможности PostgreSQL
+func New(db *gorm.DB) Searcher {
+	return &postgresSearcher{db: db}
+}
+
+// Создание объектов БД, необходимых для поиска (конфигурация и индексы)
+func Migrate(db *gorm.DB) error {
+	return migratePostgres(db)
+}
+
+func wants(types []string, t string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
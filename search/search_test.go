@@ -0,0 +1,20 @@
+package search
+
+import "testing"
+
+func TestMarkup(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "plain text", want: "plain text"},
+		{text: "\x02Отчёт\x03 за \x02март\x03", want: "<b>Отчёт</b> за <b>март</b>"},
+		{text: "<script>alert(\"x\")</script> & \x02tag\x03", want: "&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; <b>tag</b>"},
+	}
+
+	for _, tt := range tests {
+		if got := markup(tt.text); got != tt.want {
+			t.Errorf("markup(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
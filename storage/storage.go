@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Хранилище содержимого файлов; метаданные хранятся в базе
+type Store interface {
+	// Запись объекта; size — точный размер содержимого
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Чтение объекта потоком; вызывающий закрывает ReadCloser
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
@@ -1,6 +1,7 @@
 package validators
 
 import (
+	"em-test/recurrence"
 	"regexp"
 
 	"github.com/go-playground/validator/v10"
@@ -10,3 +11,8 @@ func ValidatePassportNumberFormat(fl validator.FieldLevel) bool {
     re := regexp.MustCompile(`^\d{4} \d{6}$`)
     return re.MatchString(fl.Field().String())
 }
+
+func ValidateRecurrence(fl validator.FieldLevel) bool {
+	_, err := recurrence.Parse(fl.Field().String())
+	return err == nil
+}
\ No newline at end of file
@@ -0,0 +1,163 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Первая пауза перед повтором; каждая следующая вдвое длиннее
+const baseBackoff = 30 * time.Second
+
+// Самая длинная пауза между повторами
+const maxBackoff = 6 * time.Hour
+
+// Сколько байт ответа сохраняется в журнале доставок
+const maxResponseBytes = 1024
+
+// Разрешить доставку на адреса внутренних сетей (WEBHOOKS_ALLOW_PRIVATE_NETWORKS)
+var AllowPrivateNetworks bool
+
+// Ошибка адреса вебхука во внутренней сети
+var ErrPrivateAddress = errors.New("webhook address is in a private network")
+
+// Адрес проверяется при каждом соединении, уже после разрешения имени, поэтому
+// перенаправления и DNS, указывающий на внутренний адрес, не обходят проверку.
+// Прокси из окружения не используется: иначе проверялся бы адрес прокси.
+var client = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: checkDial}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+func checkDial(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	return checkAddr(addrPort.Addr())
+}
+
+// Петлевые, частные, link-local, multicast и неуказанные адреса, а также
+// общий адрес провайдера 100.64.0.0/10 недоступны, если AllowPrivateNetworks не задан
+func checkAddr(addr netip.Addr) error {
+	if AllowPrivateNetworks {
+		return nil
+	}
+
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() || sharedAddressSpace.Contains(addr) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Проверка адреса вебхука при сохранении. Имя хоста не разрешается: адреса,
+// полученные через DNS, проверяются при отправке.
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		if AllowPrivateNetworks {
+			return nil
+		}
+		return ErrPrivateAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return checkAddr(addr)
+	}
+	return nil
+}
+
+// Подпись тела: HMAC-SHA256 от "timestamp.body" в hex с префиксом sha256=.
+// Получатель проверяет её и отбрасывает запросы со старой меткой времени.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Случайный секрет для подписи
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Пауза перед попыткой с номером attempt+1 после attempt неудачных
+func Backoff(attempt int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+// Результат попытки доставки
+type Result struct {
+	StatusCode int
+	Response   string
+}
+
+// Отправка подписанного события. Ошибка возвращается и при ответе не из диапазона 2xx.
+func Send(ctx context.Context, url, secret, eventType string, deliveryID uint, body []byte) (Result, error) {
+	var result Result
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return result, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "em-test-webhooks")
+	req.Header.Set("X-Webhook-Event", eventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(deliveryID), 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", Sign(secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	result = Result{StatusCode: resp.StatusCode, Response: string(response)}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Конечный автомат статусов задачи
+type Workflow struct {
+	Initial     string              `json:"initial"`
+	Terminal    []string            `json:"terminal"`
+	Transitions map[string][]string `json:"transitions"`
+}
+
+// Процесс по умолчанию: todo → in_progress → review → done, закрытую задачу можно переоткрыть
+func Default() *Workflow {
+	return &Workflow{
+		Initial:  "todo",
+		Terminal: []string{"done"},
+		Transitions: map[string][]string{
+			"todo":        {"in_progress"},
+			"in_progress": {"todo", "review"},
+			"review":      {"in_progress", "done"},
+			"done":        {"todo"},
+		},
+	}
+}
+
+// Загрузка процесса из JSON-файла
+func Load(path string) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var w Workflow
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, err
+	}
+
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+// Проверка, что все упомянутые статусы описаны в transitions
+func (w *Workflow) Validate() error {
+	if !w.IsState(w.Initial) {
+		return fmt.Errorf("unknown initial status %q", w.Initial)
+	}
+
+	for _, status := range w.Terminal {
+		if !w.IsState(status) {
+			return fmt.Errorf("unknown terminal status %q", status)
+		}
+	}
+
+	for from, targets := range w.Transitions {
+		for _, to := range targets {
+			if !w.IsState(to) {
+				return fmt.Errorf("transition %s -> %s leads to unknown status", from, to)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (w *Workflow) IsState(status string) bool {
+	_, ok := w.Transitions[status]
+	return ok
+}
+
+func (w *Workflow) IsTerminal(status string) bool {
+	for _, terminal := range w.Terminal {
+		if terminal == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Workflow) CanTransition(from, to string) bool {
+	for _, target := range w.Transitions[from] {
+		if target == to {
+			return true
+		}
+	}
+	return false
+}